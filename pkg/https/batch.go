@@ -0,0 +1,126 @@
+package https
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrBatchStopped is the error of the requests not sent because the batch stopped at a failed request
+var ErrBatchStopped = errors.New("batch stopped before the request was sent")
+
+// Request is a request of a batch, the URL and options are the same as for Do
+type Request struct {
+	URL     string
+	Options []func(cfg *Options)
+}
+
+// Result is the result of a request of a batch,
+// Err is set when the request failed or was not sent because the batch was stopped.
+type Result[T any] struct {
+	Data *T
+	Err  error
+}
+
+// BatchOptions represents the options for DoBatch.
+type BatchOptions struct {
+	ctx      context.Context // The context of the batch, no new request is sent once it is done.
+	failFast bool            // Stop the batch at the first failed request.
+}
+
+// WithBatchContext sets the context of the batch,
+// no new request is sent once the context is done, in-flight requests are not interrupted.
+func WithBatchContext(ctx context.Context) func(cfg *BatchOptions) {
+	return func(cfg *BatchOptions) {
+		cfg.ctx = ctx
+	}
+}
+
+// WithFailFast stops the batch at the first failed request,
+// by default all requests are sent and the errors are collected in the results.
+func WithFailFast() func(cfg *BatchOptions) {
+	return func(cfg *BatchOptions) {
+		cfg.failFast = true
+	}
+}
+
+// DoBatch sends the requests in parallel, with at most concurrency requests in flight,
+// and returns the JSON responses in the order of the requests.
+// Generic type T is the response struct, like DoJSON.
+// Every request goes through Do, so its options (e.g. WithShopifyAccessToken, WithDedup) apply as usual.
+// The returned error is the first error in fail-fast mode or the context error,
+// otherwise it is nil and the errors are in the results.
+// Example:
+//
+//	requests := make([]https.Request, len(ids))
+//	for i, id := range ids {
+//		requests[i] = https.Request{
+//			URL:     https.MakeShopifyRestURL("abc.myshopify.com", "products", id),
+//			Options: []func(cfg *https.Options){https.WithShopifyAccessToken(token)},
+//		}
+//	}
+//	results, err := https.DoBatch[ProductResp](requests, 10)
+func DoBatch[T any](requests []Request, concurrency int, options ...func(cfg *BatchOptions)) ([]Result[T], error) {
+	cfg := &BatchOptions{ctx: context.Background()}
+	for _, option := range options {
+		option(cfg)
+	}
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancelCause(cfg.ctx)
+	defer cancel(nil)
+
+	var (
+		results  = make([]Result[T], len(requests))
+		sem      = make(chan struct{}, concurrency)
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	for i, request := range requests {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+
+		if ctx.Err() != nil {
+			for j := i; j < len(requests); j++ {
+				results[j].Err = context.Cause(ctx)
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, request Request) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			data, err := DoJSON[T](request.URL, request.Options...)
+			if err != nil {
+				results[i].Err = err
+				if cfg.failFast {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel(ErrBatchStopped)
+					})
+				}
+				return
+			}
+			results[i].Data = data
+		}(i, request)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return results, firstErr
+	}
+
+	return results, cfg.ctx.Err()
+}
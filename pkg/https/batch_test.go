@@ -0,0 +1,96 @@
+package https
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoBatch(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		if r.URL.Query().Get("id") == "3" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%s}`, r.URL.Query().Get("id"))
+	}))
+	defer server.Close()
+
+	requests := make([]Request, 10)
+	for i := range requests {
+		requests[i] = Request{URL: server.URL, Options: []func(cfg *Options){WithQuery("id", fmt.Sprint(i))}}
+	}
+
+	results, err := DoBatch[struct{ ID int }](requests, 3)
+	if err != nil {
+		t.Fatalf("DoBatch returned error: %v", err)
+	}
+
+	if maxInFlight > 3 {
+		t.Errorf("Expected at most 3 requests in flight, got %d", maxInFlight)
+	}
+
+	for i, result := range results {
+		if i == 3 {
+			var e *ErrorStatusNotOK
+			if !errors.As(result.Err, &e) || e.Code != http.StatusNotFound {
+				t.Errorf("Result 3 error = %v, want status 404", result.Err)
+			}
+			continue
+		}
+		if result.Err != nil || result.Data.ID != i {
+			t.Errorf("Result %d = %+v, %v", i, result.Data, result.Err)
+		}
+	}
+}
+
+func TestDoBatch_FailFast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	requests := make([]Request, 5)
+	for i := range requests {
+		requests[i] = Request{URL: server.URL}
+	}
+
+	results, err := DoBatch[struct{}](requests, 1, WithFailFast())
+	if err == nil {
+		t.Fatal("DoBatch should return the first error in fail-fast mode")
+	}
+
+	if !errors.Is(results[len(results)-1].Err, ErrBatchStopped) {
+		t.Errorf("Last result error = %v, want ErrBatchStopped", results[len(results)-1].Err)
+	}
+}
+
+func TestDoBatch_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := DoBatch[struct{}]([]Request{{URL: "http://127.0.0.1:1"}}, 1, WithBatchContext(ctx))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("DoBatch error = %v, want context.Canceled", err)
+	}
+
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("Result error = %v, want context.Canceled", results[0].Err)
+	}
+}
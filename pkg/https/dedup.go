@@ -0,0 +1,104 @@
+package https
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// dedupDefaultHeaders are always part of the de-duplication key,
+// so requests made with different credentials are never coalesced.
+var dedupDefaultHeaders = []string{"Authorization", "X-Shopify-Access-Token"}
+
+// WithDedup coalesces concurrent identical requests into a single upstream call.
+// Requests are identical when they have the same method, URL and values for the given headers
+// (Authorization and X-Shopify-Access-Token are always compared).
+// Every caller receives its own copy of the response, so options like WithJSONRespTo work as usual.
+// Only GET and HEAD requests without a body or form values are coalesced.
+// Example:
+//
+//	https.Do(https.MakeShopifyRestURL("abc.myshopify.com", "shop"),
+//		https.WithShopifyAccessToken(token),
+//		https.WithDedup(),
+//	)
+func WithDedup(headers ...string) func(cfg *Options) {
+	return func(cfg *Options) {
+		cfg.dedup = true
+		cfg.dedupHeaders = append(cfg.dedupHeaders, headers...)
+	}
+}
+
+// flight is an in-flight upstream call shared by the coalesced requests.
+type flight struct {
+	wg   sync.WaitGroup
+	resp *fasthttp.Response // Not pooled, it is read by every waiter and released by the GC
+	err  error
+}
+
+// flightGroup tracks the in-flight calls by their de-duplication key.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// dedupGroup is the flight group used by all requests made with WithDedup.
+var dedupGroup = &flightGroup{flights: map[string]*flight{}}
+
+// do executes the request once per key and copies the shared response to resp.
+// The first caller makes the upstream call, the others wait for its result.
+func (g *flightGroup) do(key string, req *fasthttp.Request, resp *fasthttp.Response, exec func(req *fasthttp.Request, resp *fasthttp.Response) error) error {
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		f.wg.Wait()
+		if f.err != nil {
+			return f.err
+		}
+		f.resp.CopyTo(resp)
+		return nil
+	}
+
+	f := &flight{resp: &fasthttp.Response{}}
+	f.wg.Add(1)
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	f.err = exec(req, f.resp)
+
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+	f.wg.Done()
+
+	if f.err != nil {
+		return f.err
+	}
+	f.resp.CopyTo(resp)
+	return nil
+}
+
+// dedupKey returns the de-duplication key of the request,
+// an empty key means the request must not be coalesced.
+func dedupKey(req *fasthttp.Request, headers []string) string {
+	if !req.Header.IsGet() && !req.Header.IsHead() {
+		return ""
+	}
+	// The form values of WithFormReq are not part of Body()
+	if len(req.Body()) > 0 || req.PostArgs().Len() > 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.Write(req.Header.Method())
+	sb.WriteByte(' ')
+	sb.Write(req.URI().FullURI())
+	for _, h := range append(dedupDefaultHeaders, headers...) {
+		sb.WriteByte('\n')
+		sb.WriteString(h)
+		sb.WriteByte(':')
+		sb.Write(req.Header.Peek(h))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,130 @@
+package https
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestDo_WithDedup(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"name":"shop"}`))
+	}))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	names := make([]string, 10)
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := DoJSON[struct{ Name string }](server.URL, WithDedup())
+			if err != nil {
+				t.Errorf("DoJSON returned error: %v", err)
+				return
+			}
+			names[i] = resp.Name
+		}(i)
+	}
+	wg.Wait()
+
+	if hits != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", hits)
+	}
+	for i, name := range names {
+		if name != "shop" {
+			t.Errorf("Caller %d got name '%s', want 'shop'", i, name)
+		}
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	// Different access tokens must never share a response
+	var wg sync.WaitGroup
+	for _, token := range []string{"token-a", "token-b"} {
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			_ = Do(server.URL, WithShopifyAccessToken(token), WithDedup())
+		}(token)
+	}
+	wg.Wait()
+
+	if hits != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", hits)
+	}
+}
+
+func TestDedupKey_Form(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(100 * time.Millisecond)
+		_ = r.ParseForm()
+		w.Write([]byte(`{"name":"` + r.PostForm.Get("v") + `"}`))
+	}))
+	defer server.Close()
+
+	// Form POSTs to the same URL must each get their own response
+	var wg sync.WaitGroup
+	names := map[string]string{}
+	var mu sync.Mutex
+	for _, v := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(v string) {
+			defer wg.Done()
+			resp, err := DoJSON[struct{ Name string }](server.URL, WithMethod(POST), WithFormReq(M{"v": v}), WithDedup())
+			if err != nil {
+				t.Errorf("DoJSON returned error: %v", err)
+				return
+			}
+			mu.Lock()
+			names[v] = resp.Name
+			mu.Unlock()
+		}(v)
+	}
+	wg.Wait()
+
+	if hits != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", hits)
+	}
+	for v, name := range names {
+		if name != v {
+			t.Errorf("Caller %s got name '%s'", v, name)
+		}
+	}
+}
+
+func TestDedupKey_Coalesced(t *testing.T) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI("https://example.com/shop")
+
+	if dedupKey(req, nil) == "" {
+		t.Error("Expected a key for a GET")
+	}
+	req.PostArgs().Set("v", "a")
+	if dedupKey(req, nil) != "" {
+		t.Error("Expected no key for a request with form values")
+	}
+	req.PostArgs().Reset()
+	req.Header.SetMethod("POST")
+	if dedupKey(req, nil) != "" {
+		t.Error("Expected no key for a POST")
+	}
+}
@@ -53,6 +53,16 @@ func Do(url string, options ...func(cfg *Options)) (err error) {
 		cfg.headers["Accept"] = "application/json"
 	}
 
+	if cfg.shopifyAPIVersion != "" {
+		url = setShopifyAPIVersion(url, cfg.shopifyAPIVersion)
+	}
+
+	// Shopify REST requests are paced with the leaky bucket of the shop
+	var bucket *shopifyBucket
+	if _, ok := cfg.headers["X-Shopify-Access-Token"]; ok && !cfg.noShopifyRateLimit {
+		bucket = getShopifyBucket(url)
+	}
+
 	req := fasthttp.AcquireRequest()
 	defer fasthttp.ReleaseRequest(req)
 
@@ -115,10 +125,33 @@ func Do(url string, options ...func(cfg *Options)) (err error) {
 	resp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseResponse(resp)
 
-	if err = fastHttpClient.Do(req, resp); err != nil {
-		return fmt.Errorf("failed to execute HTTP request: %w", err)
+	for attempt := 0; ; attempt++ {
+		if bucket != nil {
+			time.Sleep(bucket.reserve(time.Now()))
+		}
+
+		if cfg.dedup {
+			if key := dedupKey(req, cfg.dedupHeaders); key != "" {
+				err = dedupGroup.do(key, req, resp, execute)
+			} else {
+				err = execute(req, resp)
+			}
+		} else {
+			err = execute(req, resp)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if bucket == nil || !bucket.update(resp, time.Now()) || attempt >= shopifyRESTMaxRetries {
+			break
+		}
+		resp.Reset()
 	}
 
+	checkShopifyAPIVersion(url, resp)
+
 	if cfg.headerResp != nil {
 		resp.Header.VisitAll(func(k, v []byte) {
 			cfg.headerResp[string(k)] = string(v)
@@ -154,6 +187,15 @@ func Do(url string, options ...func(cfg *Options)) (err error) {
 	return nil
 }
 
+// execute sends the request with the shared fasthttp client
+func execute(req *fasthttp.Request, resp *fasthttp.Response) error {
+	if err := fastHttpClient.Do(req, resp); err != nil {
+		return fmt.Errorf("failed to execute HTTP request: %w", err)
+	}
+
+	return nil
+}
+
 // DoText makes a request to the given URL and returns the response body as a string
 func DoText(url string, options ...func(cfg *Options)) (*string, error) {
 	var resp string
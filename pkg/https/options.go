@@ -31,6 +31,11 @@ type Options struct {
 	headerResp    map[string]string // Reference to a variable where the response headers will be stored.
 	timeout       int               // The request timeout in seconds.
 	proxyProvider GoProxyProvider   // The Go proxy provider to use for the request.
+	dedup         bool              // Coalesce concurrent identical requests into a single upstream call.
+	dedupHeaders  []string          // The headers that are part of the de-duplication key.
+
+	noShopifyRateLimit bool   // Disable the Shopify REST leaky-bucket pacing.
+	shopifyAPIVersion  string // The Shopify API version of the request, replacing the one in the URL.
 }
 
 // WithMethod sets the request method (GET, POST, PUT, DELETE, PATCH)
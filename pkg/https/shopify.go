@@ -1,12 +1,20 @@
 package https
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// WithShopifyAccessToken sets the request header X-Shopify-Access-Token
+// WithShopifyAccessToken sets the request header X-Shopify-Access-Token.
+// REST requests (MakeShopifyRestURL) are paced with a leaky bucket per shop,
+// synced from the X-Shopify-Shop-Api-Call-Limit header, and retried after Retry-After on 429.
+// Use WithoutShopifyRateLimit to disable it.
 func WithShopifyAccessToken(accessToken string) func(cfg *Options) {
 	return func(cfg *Options) {
 		if cfg.headers == nil {
@@ -20,30 +28,71 @@ func WithShopifyAccessToken(accessToken string) func(cfg *Options) {
 // do not use this variable directly, use getShopifyAPIVersion instead.
 var shopifyAPIVersion = ""
 
+// shopifyAPIVersionOnce makes getShopifyAPIVersion read the environment only once.
+var shopifyAPIVersionOnce sync.Once
+
 // shopifyAPIVersion is the Shopify API version.
-// It can be set by the environment variable SHOPIFY_API_VERSION.
+// It can be set by the environment variable SHOPIFY_API_VERSION,
+// it is checked against the release calendar (see ValidateShopifyAPIVersion).
 // Check https://shopify.dev/docs/api/release-notes for the latest version.
-// If SHOPIFY_API_VERSION is not set, it will use the version 3 months ago.
+// If SHOPIFY_API_VERSION is not set or invalid, it will use the version 2 months ago, rounded to a quarter.
 var getShopifyAPIVersion = func() string {
-	if shopifyAPIVersion == "" {
-		if shopifyAPIVersion = os.Getenv("SHOPIFY_API_VERSION"); shopifyAPIVersion == "" {
-			d := time.Now().AddDate(0, -2, 0)
-			month := ((d.Month()-1)/3)*3 + 1 // 1, 4, 7, 10
-			shopifyAPIVersion = fmt.Sprintf("%d-%02d", d.Year(), month)
-
-			fmt.Println("WARNING: SHOPIFY_API_VERSION is not set, using", shopifyAPIVersion)
+	shopifyAPIVersionOnce.Do(func() {
+		shopifyAPIVersion = os.Getenv("SHOPIFY_API_VERSION")
+		if shopifyAPIVersion == "" {
+			shopifyAPIVersion = defaultShopifyAPIVersion(time.Now())
+			slog.Warn("SHOPIFY_API_VERSION is not set", "version", shopifyAPIVersion)
+		} else if err := ValidateShopifyAPIVersion(shopifyAPIVersion); errors.Is(err, ErrShopifyAPIVersionInvalid) {
+			slog.Error("SHOPIFY_API_VERSION is invalid", "version", shopifyAPIVersion, "err", err)
+			shopifyAPIVersion = defaultShopifyAPIVersion(time.Now())
+		} else if err != nil {
+			slog.Warn("SHOPIFY_API_VERSION is not supported", "version", shopifyAPIVersion, "err", err)
 		}
-	}
+	})
 
 	return shopifyAPIVersion
 }
 
+// defaultShopifyAPIVersion returns the version released 2 months before now, rounded to a quarter
+func defaultShopifyAPIVersion(now time.Time) string {
+	d := now.AddDate(0, -2, 0)
+	month := ((d.Month()-1)/3)*3 + 1 // 1, 4, 7, 10
+	return fmt.Sprintf("%d-%02d", d.Year(), month)
+}
+
+// shopifyBaseURL replaces https://<shop> in the Shopify URLs when set, see SetShopifyBaseURL.
+var shopifyBaseURL atomic.Pointer[string]
+
+// SetShopifyBaseURL makes MakeShopifyGraphqlURL, MakeShopifyRestURL and MakeShopifyURL send the requests of every shop
+// to baseURL, with the shop as the first path segment (e.g. http://127.0.0.1:8080/abc.myshopify.com/admin/api/...).
+// It is meant for tests with a stand-in server (see package shopifytest), an empty baseURL removes the override.
+func SetShopifyBaseURL(baseURL string) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	shopifyBaseURL.Store(&baseURL)
+}
+
+// shopifyShopURL returns the URL of the shop, https://<shop> or the base URL override
+func shopifyShopURL(myShopifyDomain string) string {
+	if base := shopifyBaseURL.Load(); base != nil && *base != "" {
+		return *base + "/" + myShopifyDomain
+	}
+	return "https://" + myShopifyDomain
+}
+
+// MakeShopifyURL returns the URL of the path on the shop (e.g. /admin/oauth/access_token),
+// https://<shop><path> or behind the base URL of SetShopifyBaseURL.
+func MakeShopifyURL(myShopifyDomain, path string) string {
+	return shopifyShopURL(myShopifyDomain) + path
+}
+
 // MakeShopifyGraphqlURL returns the Shopify GraphQL URL
+// The version is the one set for the shop by SetShopifyAPIVersion, or SHOPIFY_API_VERSION.
 func MakeShopifyGraphqlURL(myShopifyDomain string) string {
-	return fmt.Sprintf("https://%s/admin/api/%s/graphql.json", myShopifyDomain, getShopifyAPIVersion())
+	return fmt.Sprintf("%s/admin/api/%s/graphql.json", shopifyShopURL(myShopifyDomain), getShopAPIVersion(myShopifyDomain))
 }
 
 // MakeShopifyRestURL returns the Shopify REST URL,
+// the version is the one set for the shop by SetShopifyAPIVersion, or SHOPIFY_API_VERSION.
 // resources is a list of resources (e.g. orders, products, customers).
 // Example:
 //
@@ -61,5 +110,5 @@ func MakeShopifyRestURL(myShopifyDomain string, resources ...any) string {
 		resourcesStr += fmt.Sprintf("/%v", r)
 	}
 
-	return fmt.Sprintf("https://%s/admin/api/%s%s.json", myShopifyDomain, getShopifyAPIVersion(), resourcesStr)
+	return fmt.Sprintf("%s/admin/api/%s%s.json", shopifyShopURL(myShopifyDomain), getShopAPIVersion(myShopifyDomain), resourcesStr)
 }
\ No newline at end of file
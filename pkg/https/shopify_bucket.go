@@ -0,0 +1,115 @@
+package https
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// shopifyRESTMaxRetries is the maximum number of retries of a REST request rejected with 429.
+	shopifyRESTMaxRetries = 3
+	// shopifyRESTHeadroom is the part of the bucket kept free, the requests are paced before it fills.
+	shopifyRESTHeadroom = 0.1
+	// shopifyRESTDefaultSize is the bucket size of a standard shop (80 for Shopify Plus).
+	shopifyRESTDefaultSize = 40
+	// shopifyRESTDefaultRetryAfter is the delay before retrying a 429 without Retry-After.
+	shopifyRESTDefaultRetryAfter = time.Second
+)
+
+// shopifyBuckets holds the REST leaky bucket of each shop.
+var shopifyBuckets sync.Map
+
+// WithoutShopifyRateLimit disables the REST leaky-bucket pacing enabled by WithShopifyAccessToken
+func WithoutShopifyRateLimit() func(cfg *Options) {
+	return func(cfg *Options) {
+		cfg.noShopifyRateLimit = true
+	}
+}
+
+// shopifyBucket is the REST leaky bucket of a shop, mirrored from X-Shopify-Shop-Api-Call-Limit.
+// Shopify leaks size/20 calls per second (2/s for 40, 4/s for 80 on Shopify Plus).
+type shopifyBucket struct {
+	mu          sync.Mutex
+	level       float64   // Calls in the bucket at updatedAt
+	size        float64   // Size of the bucket
+	updatedAt   time.Time // When level was computed
+	pausedUntil time.Time // No request before this time (Retry-After)
+}
+
+// getShopifyBucket returns the bucket of the shop if the URL is a Shopify REST URL, nil otherwise.
+// The bucket is keyed by the part of the URL before /admin/api/, the host or the shop path of SetShopifyBaseURL.
+func getShopifyBucket(url string) *shopifyBucket {
+	url, _, _ = strings.Cut(strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://"), "?")
+	i := strings.Index(url, "/admin/api/")
+	if i <= 0 || strings.HasSuffix(url, "/graphql.json") {
+		return nil
+	}
+
+	bucket, _ := shopifyBuckets.LoadOrStore(url[:i], &shopifyBucket{size: shopifyRESTDefaultSize})
+	return bucket.(*shopifyBucket)
+}
+
+// leak returns the level at the given time
+func (b *shopifyBucket) leak(now time.Time) float64 {
+	level := b.level - b.size/20*now.Sub(b.updatedAt).Seconds()
+	if level < 0 {
+		return 0
+	}
+	return level
+}
+
+// reserve adds a call to the bucket and returns how long to wait before sending it
+func (b *shopifyBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var wait time.Duration
+	if now.Before(b.pausedUntil) {
+		wait = b.pausedUntil.Sub(now)
+	}
+
+	level := b.leak(now) + 1
+	if limit := b.size * (1 - shopifyRESTHeadroom); level > limit {
+		if d := time.Duration((level - limit) / (b.size / 20) * float64(time.Second)); d > wait {
+			wait = d
+		}
+	}
+
+	b.level = level
+	b.updatedAt = now
+	return wait
+}
+
+// update syncs the bucket with the response,
+// it returns true if the request was rejected with 429, the next reserve waits for Retry-After.
+func (b *shopifyBucket) update(resp *fasthttp.Response, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// X-Shopify-Shop-Api-Call-Limit: 32/40
+	if used, size, ok := strings.Cut(string(resp.Header.Peek("X-Shopify-Shop-Api-Call-Limit")), "/"); ok {
+		usedN, err1 := strconv.ParseFloat(used, 64)
+		sizeN, err2 := strconv.ParseFloat(size, 64)
+		if err1 == nil && err2 == nil && sizeN > 0 {
+			b.level = usedN
+			b.size = sizeN
+			b.updatedAt = now
+		}
+	}
+
+	if resp.StatusCode() != fasthttp.StatusTooManyRequests {
+		return false
+	}
+
+	retryAfter := shopifyRESTDefaultRetryAfter
+	if seconds, err := strconv.ParseFloat(string(resp.Header.Peek("Retry-After")), 64); err == nil {
+		retryAfter = time.Duration(seconds * float64(time.Second))
+	}
+	b.pausedUntil = now.Add(retryAfter)
+
+	return true
+}
@@ -0,0 +1,100 @@
+package https
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShopifyBucket_Reserve(t *testing.T) {
+	now := time.Now()
+	bucket := &shopifyBucket{size: 40, level: 35, updatedAt: now}
+
+	// 36 calls fit in the bucket (10% headroom)
+	if wait := bucket.reserve(now); wait != 0 {
+		t.Errorf("Expected no wait, got %v", wait)
+	}
+
+	// The 37th call waits for one call to leak (2 calls per second)
+	if wait := bucket.reserve(now); wait != 500*time.Millisecond {
+		t.Errorf("Expected 500ms wait, got %v", wait)
+	}
+
+	// After 10 seconds, 20 calls have leaked
+	if wait := bucket.reserve(now.Add(10 * time.Second)); wait != 0 {
+		t.Errorf("Expected no wait after leaking, got %v", wait)
+	}
+}
+
+func TestGetShopifyBucket(t *testing.T) {
+	if getShopifyBucket(MakeShopifyRestURL("abc.myshopify.com", "products")) == nil {
+		t.Error("Expected a bucket for a REST URL")
+	}
+
+	if getShopifyBucket(MakeShopifyGraphqlURL("abc.myshopify.com")) != nil {
+		t.Error("Expected no bucket for a GraphQL URL")
+	}
+
+	if getShopifyBucket("https://example.com/products.json") != nil {
+		t.Error("Expected no bucket for a non Shopify URL")
+	}
+}
+
+func TestSetShopifyBaseURL(t *testing.T) {
+	SetShopifyBaseURL("http://127.0.0.1:8080/")
+	defer SetShopifyBaseURL("")
+
+	url := MakeShopifyRestURL("abc.myshopify.com", "products")
+	if !strings.HasPrefix(url, "http://127.0.0.1:8080/abc.myshopify.com/admin/api/") {
+		t.Errorf("Unexpected REST URL: %s", url)
+	}
+	if url := MakeShopifyURL("abc.myshopify.com", "/admin/oauth/access_token"); url != "http://127.0.0.1:8080/abc.myshopify.com/admin/oauth/access_token" {
+		t.Errorf("Unexpected shop URL: %s", url)
+	}
+
+	// Each shop keeps its own bucket behind the base URL
+	if a, b := getShopifyBucket(url), getShopifyBucket(MakeShopifyRestURL("xyz.myshopify.com", "products")); a == nil || a == b {
+		t.Error("Expected a different bucket per shop")
+	}
+
+	SetShopifyBaseURL("")
+	if url = MakeShopifyGraphqlURL("abc.myshopify.com"); !strings.HasPrefix(url, "https://abc.myshopify.com/admin/api/") {
+		t.Errorf("Unexpected GraphQL URL after reset: %s", url)
+	}
+}
+
+func TestDo_ShopifyRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0.1")
+			w.Header().Set("X-Shopify-Shop-Api-Call-Limit", "10/40")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("X-Shopify-Shop-Api-Call-Limit", "1/80")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/admin/api/2024-01/products.json"
+	start := time.Now()
+	if err := Do(url, WithShopifyAccessToken("token")); err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected the retry to wait for Retry-After, took %v", elapsed)
+	}
+
+	bucket := getShopifyBucket(url)
+	if bucket.size != 80 {
+		t.Errorf("Expected bucket size 80 from the header, got %v", bucket.size)
+	}
+}
@@ -0,0 +1,163 @@
+package https
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// shopifyAPIVersionSupport is how long a stable version is supported after its release.
+const shopifyAPIVersionSupport = 12
+
+var (
+	// ErrShopifyAPIVersionInvalid is returned for a version that is not in the release calendar.
+	ErrShopifyAPIVersionInvalid = errors.New("invalid Shopify API version")
+	// ErrShopifyAPIVersionUnsupported is returned for a version that is not released yet or no longer supported.
+	ErrShopifyAPIVersionUnsupported = errors.New("unsupported Shopify API version")
+)
+
+var (
+	// shopifyAPIVersionRegexp matches a stable version, released every quarter (e.g. 2024-01).
+	shopifyAPIVersionRegexp = regexp.MustCompile(`^(\d{4})-(01|04|07|10)$`)
+	// shopifyAPIVersionPath matches the version in a Shopify Admin API URL.
+	shopifyAPIVersionPath = regexp.MustCompile(`/admin/api/[^/]+/`)
+	// shopAPIVersions holds the version of each shop set by SetShopifyAPIVersion.
+	shopAPIVersions sync.Map
+	// shopifyDeprecatedCalls counts the responses with X-Shopify-API-Deprecated-Reason.
+	shopifyDeprecatedCalls atomic.Uint64
+	// shopifyResourceID matches a resource ID in a REST path (e.g. /products/123.json).
+	shopifyResourceID = regexp.MustCompile(`/\d+\b`)
+	// shopifyDeprecationsLogged holds the deprecations already logged, each one is logged once.
+	// Keyed by version, path template and reason, so that it stays small in a long-running process.
+	shopifyDeprecationsLogged sync.Map
+)
+
+// ValidateShopifyAPIVersion checks the version against the release calendar:
+// a stable version is released every quarter (January, April, July, October)
+// and supported for 12 months. "unstable" is always valid.
+// Returns ErrShopifyAPIVersionInvalid or ErrShopifyAPIVersionUnsupported (wrapped).
+func ValidateShopifyAPIVersion(version string) error {
+	return validateShopifyAPIVersion(version, time.Now())
+}
+
+// validateShopifyAPIVersion checks the version at the given time
+func validateShopifyAPIVersion(version string, now time.Time) error {
+	if version == "unstable" {
+		return nil
+	}
+
+	if !shopifyAPIVersionRegexp.MatchString(version) {
+		return fmt.Errorf("%w: %s", ErrShopifyAPIVersionInvalid, version)
+	}
+
+	released, _ := time.Parse("2006-01", version)
+	if now.Before(released) {
+		return fmt.Errorf("%w: %s is not released yet", ErrShopifyAPIVersionUnsupported, version)
+	}
+
+	if !now.Before(released.AddDate(0, shopifyAPIVersionSupport, 0)) {
+		return fmt.Errorf("%w: %s is no longer supported", ErrShopifyAPIVersionUnsupported, version)
+	}
+
+	return nil
+}
+
+// SetShopifyAPIVersion sets the API version used by MakeShopifyGraphqlURL and MakeShopifyRestURL for a shop,
+// an empty version removes the override.
+// Returns an error if the version is not in the release calendar.
+func SetShopifyAPIVersion(myShopifyDomain, version string) error {
+	if version == "" {
+		shopAPIVersions.Delete(myShopifyDomain)
+		return nil
+	}
+
+	if err := ValidateShopifyAPIVersion(version); errors.Is(err, ErrShopifyAPIVersionInvalid) {
+		return err
+	}
+
+	shopAPIVersions.Store(myShopifyDomain, version)
+	return nil
+}
+
+// getShopAPIVersion returns the version of the shop, SHOPIFY_API_VERSION if it has none
+func getShopAPIVersion(myShopifyDomain string) string {
+	if version, ok := shopAPIVersions.Load(myShopifyDomain); ok {
+		return version.(string)
+	}
+	return getShopifyAPIVersion()
+}
+
+// WithShopifyAPIVersion sets the API version of a single request,
+// it replaces the version of a URL made by MakeShopifyGraphqlURL or MakeShopifyRestURL.
+// Example:
+//
+//	https.Do(https.MakeShopifyGraphqlURL("abc.myshopify.com"),
+//		https.WithShopifyAccessToken(token),
+//		https.WithShopifyAPIVersion("2025-01"),
+//	)
+func WithShopifyAPIVersion(version string) func(cfg *Options) {
+	return func(cfg *Options) {
+		cfg.shopifyAPIVersion = version
+	}
+}
+
+// setShopifyAPIVersion replaces the version in a Shopify Admin API URL
+func setShopifyAPIVersion(url, version string) string {
+	return shopifyAPIVersionPath.ReplaceAllLiteralString(url, "/admin/api/"+version+"/")
+}
+
+// ShopifyDeprecatedCalls returns the number of responses with the X-Shopify-API-Deprecated-Reason header
+// since the process started, useful to export as a metric.
+func ShopifyDeprecatedCalls() uint64 {
+	return shopifyDeprecatedCalls.Load()
+}
+
+// checkShopifyAPIVersion reports the deprecated calls and the version fallbacks of a response.
+// Each deprecation is logged once per version and path template, but all of them are counted.
+func checkShopifyAPIVersion(url string, resp *fasthttp.Response) {
+	reason := resp.Header.Peek("X-Shopify-API-Deprecated-Reason")
+	returned := resp.Header.Peek("X-Shopify-API-Version")
+	if len(reason) == 0 && len(returned) == 0 {
+		return
+	}
+
+	endpoint, _, _ := strings.Cut(url, "?")
+	requested := ""
+	if loc := shopifyAPIVersionPath.FindString(endpoint); loc != "" {
+		requested = strings.TrimSuffix(strings.TrimPrefix(loc, "/admin/api/"), "/")
+	}
+
+	if len(reason) > 0 {
+		shopifyDeprecatedCalls.Add(1)
+		key := requested + " " + shopifyPathTemplate(endpoint) + " " + string(reason)
+		if _, logged := shopifyDeprecationsLogged.LoadOrStore(key, true); !logged {
+			slog.Warn("Shopify API deprecated call", "url", endpoint, "version", string(returned), "reason", string(reason))
+		}
+	}
+
+	// Shopify falls back to the oldest supported version when the requested one is not supported
+	if len(returned) > 0 && requested != "" && requested != "unstable" && requested != string(returned) {
+		if _, logged := shopifyDeprecationsLogged.LoadOrStore("version "+requested, true); !logged {
+			slog.Warn("Shopify API version not supported", "requested", requested, "returned", string(returned))
+		}
+	}
+}
+
+// shopifyPathTemplate returns the path of an Admin API URL without the shop, the version and the resource IDs,
+// e.g. https://abc.myshopify.com/admin/api/2025-01/products/123.json is products/:id.json
+func shopifyPathTemplate(endpoint string) string {
+	path := endpoint
+	if loc := shopifyAPIVersionPath.FindStringIndex(endpoint); loc != nil {
+		path = endpoint[loc[1]:]
+	} else if _, rest, ok := strings.Cut(endpoint, "://"); ok {
+		_, path, _ = strings.Cut(rest, "/")
+	}
+	return shopifyResourceID.ReplaceAllLiteralString(path, "/:id")
+}
@@ -0,0 +1,82 @@
+package https
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestValidateShopifyAPIVersion(t *testing.T) {
+	now := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		version string
+		wantErr error
+	}{
+		{"2025-01", nil},
+		{"2024-04", nil},
+		{"unstable", nil},
+		{"2024-01", ErrShopifyAPIVersionUnsupported}, // Older than 12 months
+		{"2025-04", ErrShopifyAPIVersionUnsupported}, // Not released yet
+		{"2025-02", ErrShopifyAPIVersionInvalid},
+		{"latest", ErrShopifyAPIVersionInvalid},
+	}
+
+	for _, tt := range tests {
+		err := validateShopifyAPIVersion(tt.version, now)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("validateShopifyAPIVersion(%q) = %v, want %v", tt.version, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSetShopifyAPIVersion(t *testing.T) {
+	if err := SetShopifyAPIVersion("abc.myshopify.com", "2025-13"); err == nil {
+		t.Error("SetShopifyAPIVersion should reject an invalid version")
+	}
+
+	_ = SetShopifyAPIVersion("abc.myshopify.com", "unstable")
+	defer SetShopifyAPIVersion("abc.myshopify.com", "")
+
+	if url := MakeShopifyGraphqlURL("abc.myshopify.com"); url != "https://abc.myshopify.com/admin/api/unstable/graphql.json" {
+		t.Errorf("Unexpected URL: %s", url)
+	}
+
+	if url := setShopifyAPIVersion(MakeShopifyRestURL("abc.myshopify.com", "orders", 1), "2025-01"); url != "https://abc.myshopify.com/admin/api/2025-01/orders/1.json" {
+		t.Errorf("Unexpected URL: %s", url)
+	}
+}
+
+func TestDo_ShopifyDeprecatedCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Shopify-API-Version", "2025-01")
+		w.Header().Set("X-Shopify-API-Deprecated-Reason", "https://shopify.dev/changelog/deprecated")
+	}))
+	defer server.Close()
+
+	before := ShopifyDeprecatedCalls()
+	for i := 0; i < 2; i++ {
+		if err := Do(server.URL+"/admin/api/2024-01/graphql.json", WithShopifyAPIVersion("2025-01")); err != nil {
+			t.Fatalf("Do returned error: %v", err)
+		}
+	}
+
+	if calls := ShopifyDeprecatedCalls() - before; calls != 2 {
+		t.Errorf("Expected 2 deprecated calls, got %d", calls)
+	}
+}
+
+func TestShopifyPathTemplate(t *testing.T) {
+	tests := map[string]string{
+		"https://abc.myshopify.com/admin/api/2025-01/products/123.json":            "products/:id.json",
+		"https://xyz.myshopify.com/admin/api/2025-01/products/456/variants/7.json": "products/:id/variants/:id.json",
+		"https://abc.myshopify.com/admin/api/2025-01/graphql.json":                 "graphql.json",
+		"https://abc.myshopify.com/admin/oauth/access_token":                       "admin/oauth/access_token",
+	}
+	for url, want := range tests {
+		if got := shopifyPathTemplate(url); got != want {
+			t.Errorf("shopifyPathTemplate(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
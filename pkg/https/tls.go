@@ -0,0 +1,368 @@
+package https
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// tlsReloadCheckInterval is the minimum time between two checks of the certificate files on disk.
+var tlsReloadCheckInterval = 10 * time.Second
+
+// TLSOptions represents the TLS options of the shared HTTP client.
+type TLSOptions struct {
+	certFile    string              // The client certificate file (PEM).
+	keyFile     string              // The client private key file (PEM).
+	certPEM     []byte              // The client certificate (PEM).
+	keyPEM      []byte              // The client private key (PEM).
+	rootCAFiles []string            // The root CA bundle files (PEM), reloaded when they change.
+	rootCAPEMs  [][]byte            // The root CA bundles (PEM).
+	pins        map[string][][]byte // The SHA-256 hashes of the pinned public keys (SPKI) by host.
+	pinErr      error               // The first invalid pin, returned by ConfigureTLS.
+	minVersion  uint16              // The minimum TLS version (e.g. tls.VersionTLS12).
+	maxVersion  uint16              // The maximum TLS version (e.g. tls.VersionTLS13).
+}
+
+// WithClientCertFile sets the client certificate and private key files for mTLS.
+// The files are reloaded when they change on disk, without restarting the process.
+func WithClientCertFile(certFile, keyFile string) func(cfg *TLSOptions) {
+	return func(cfg *TLSOptions) {
+		cfg.certFile = certFile
+		cfg.keyFile = keyFile
+	}
+}
+
+// WithClientCertPEM sets the client certificate and private key for mTLS from PEM bytes
+func WithClientCertPEM(certPEM, keyPEM []byte) func(cfg *TLSOptions) {
+	return func(cfg *TLSOptions) {
+		cfg.certPEM = certPEM
+		cfg.keyPEM = keyPEM
+	}
+}
+
+// WithRootCAFile adds root CA bundle files to the system roots.
+// The files are reloaded when they change on disk, without restarting the process.
+func WithRootCAFile(files ...string) func(cfg *TLSOptions) {
+	return func(cfg *TLSOptions) {
+		cfg.rootCAFiles = append(cfg.rootCAFiles, files...)
+	}
+}
+
+// WithRootCAPEM adds root CA bundles from PEM bytes to the system roots
+func WithRootCAPEM(bundles ...[]byte) func(cfg *TLSOptions) {
+	return func(cfg *TLSOptions) {
+		cfg.rootCAPEMs = append(cfg.rootCAPEMs, bundles...)
+	}
+}
+
+// WithSPKIPins pins the public keys accepted for the given host.
+// A pin is the base64 encoded SHA-256 hash of the certificate's SubjectPublicKeyInfo,
+// with or without the "sha256/" prefix. The connection is accepted when any certificate
+// of a verified chain matches one of the pins, the certificates appended by the server
+// are not trusted. Other hosts are not affected.
+// An invalid pin makes ConfigureTLS return an error.
+// To get the pin of a server:
+//
+//	openssl s_client -connect host:443 | openssl x509 -pubkey -noout |
+//		openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func WithSPKIPins(host string, pins ...string) func(cfg *TLSOptions) {
+	return func(cfg *TLSOptions) {
+		if cfg.pins == nil {
+			cfg.pins = map[string][][]byte{}
+		}
+		for _, pin := range pins {
+			hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256/"))
+			if err == nil && len(hash) != sha256.Size {
+				err = fmt.Errorf("%d bytes, want %d", len(hash), sha256.Size)
+			}
+			if err != nil {
+				if cfg.pinErr == nil {
+					cfg.pinErr = fmt.Errorf("https: invalid SPKI pin %q for %s: %w", pin, host, err)
+				}
+				continue
+			}
+			cfg.pins[host] = append(cfg.pins[host], hash)
+		}
+	}
+}
+
+// WithTLSVersion sets the minimum and maximum TLS versions (e.g. tls.VersionTLS12, tls.VersionTLS13),
+// zero keeps the Go default.
+func WithTLSVersion(min, max uint16) func(cfg *TLSOptions) {
+	return func(cfg *TLSOptions) {
+		cfg.minVersion = min
+		cfg.maxVersion = max
+	}
+}
+
+// ConfigureTLS sets the TLS configuration of the shared HTTP client.
+// It must be called before the first request, because connections to a host keep
+// the configuration they were created with.
+// Example:
+//
+//	err := https.ConfigureTLS(
+//		https.WithClientCertFile("/etc/certs/client.crt", "/etc/certs/client.key"),
+//		https.WithRootCAFile("/etc/certs/internal-ca.pem"),
+//		https.WithSPKIPins("partner.example.com", "sha256/AAAA...="),
+//		https.WithTLSVersion(tls.VersionTLS12, 0),
+//	)
+func ConfigureTLS(options ...func(cfg *TLSOptions)) error {
+	configs, err := newHostTLSConfigs(options...)
+	if err != nil {
+		return err
+	}
+
+	UpdateClient(configs.apply)
+
+	return nil
+}
+
+// hostTLSConfigs builds the TLS configuration of each host from the same options,
+// the host is needed to verify the server certificate and its pins.
+type hostTLSConfigs struct {
+	base  *tls.Config                   // The configuration shared by all hosts.
+	roots *fileReloader[*x509.CertPool] // The custom roots, nil to use the default verification.
+	pins  map[string][][]byte           // The pinned public keys by host.
+}
+
+// newHostTLSConfigs loads the certificates from the given options
+func newHostTLSConfigs(options ...func(cfg *TLSOptions)) (*hostTLSConfigs, error) {
+	cfg := &TLSOptions{}
+	for _, option := range options {
+		option(cfg)
+	}
+	if cfg.pinErr != nil {
+		return nil, cfg.pinErr
+	}
+
+	configs := &hostTLSConfigs{
+		base: &tls.Config{
+			MinVersion: cfg.minVersion,
+			MaxVersion: cfg.maxVersion,
+		},
+		pins: cfg.pins,
+	}
+
+	if cfg.certFile != "" {
+		certs, err := newFileReloader([]string{cfg.certFile, cfg.keyFile}, func(files []string) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(files[0], files[1])
+			return &cert, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		configs.base.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return certs.get(), nil
+		}
+	} else if cfg.certPEM != nil {
+		cert, err := tls.X509KeyPair(cfg.certPEM, cfg.keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		configs.base.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(cfg.rootCAFiles) > 0 || len(cfg.rootCAPEMs) > 0 {
+		roots, err := newFileReloader(cfg.rootCAFiles, func(files []string) (*x509.CertPool, error) {
+			return newCertPool(files, cfg.rootCAPEMs)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load root CAs: %w", err)
+		}
+		configs.roots = roots
+	}
+
+	return configs, nil
+}
+
+// apply sets the configurations on the client, after the ConfigureClient hook already set
+func (c *hostTLSConfigs) apply(client *fasthttp.Client) {
+	client.TLSConfig = c.base
+	configure := client.ConfigureClient
+	client.ConfigureClient = func(hc *fasthttp.HostClient) error {
+		if configure != nil {
+			if err := configure(hc); err != nil {
+				return err
+			}
+		}
+
+		host, _, err := net.SplitHostPort(hc.Addr)
+		if err != nil {
+			host = hc.Addr
+		}
+		hc.TLSConfig = c.forHost(host)
+		return nil
+	}
+}
+
+// forHost returns the TLS configuration used to connect to the host
+func (c *hostTLSConfigs) forHost(host string) *tls.Config {
+	hostPins, pinned := c.pins[host]
+	if c.roots == nil && !pinned {
+		return c.base
+	}
+
+	tlsConfig := c.base.Clone()
+	if c.roots != nil {
+		// The chain is verified in VerifyConnection with the current roots,
+		// so the CA files can be reloaded without rebuilding the client.
+		tlsConfig.InsecureSkipVerify = true
+	}
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		chains := cs.VerifiedChains
+		if c.roots != nil {
+			var err error
+			if chains, err = verifyChain(host, cs.PeerCertificates, c.roots.get()); err != nil {
+				return err
+			}
+		}
+
+		if pinned {
+			return verifyPins(host, chains, hostPins)
+		}
+		return nil
+	}
+
+	return tlsConfig
+}
+
+// newCertPool returns the system roots with the CA bundles from the given files and PEM bytes
+func newCertPool(files []string, bundles [][]byte) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	bundles = append([][]byte{}, bundles...)
+	for _, file := range files {
+		bundle, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, bundle)
+	}
+
+	for _, bundle := range bundles {
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.New("no certificate found in CA bundle")
+		}
+	}
+
+	return pool, nil
+}
+
+// verifyChain verifies the server certificate chain against the given roots,
+// like crypto/tls does when InsecureSkipVerify is false.
+func verifyChain(host string, certs []*x509.Certificate, roots *x509.CertPool) ([][]*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("tls: server did not provide a certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	return certs[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+}
+
+// verifyPins checks that a certificate of a verified chain matches one of the pins of the host
+func verifyPins(host string, chains [][]*x509.Certificate, pins [][]byte) error {
+	for _, chain := range chains {
+		for _, cert := range chain {
+			hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pin := range pins {
+				if string(pin) == string(hash[:]) {
+					return nil
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("tls: no certificate of %s matches the pinned public keys", host)
+}
+
+// fileReloader holds a value loaded from files and reloads it when the files change.
+// If reloading fails, the previous value is kept and the error is logged.
+type fileReloader[V any] struct {
+	mu       sync.Mutex
+	files    []string
+	modTimes []time.Time
+	checked  time.Time
+	value    V
+	load     func(files []string) (V, error)
+}
+
+// newFileReloader loads the value for the first time
+func newFileReloader[V any](files []string, load func(files []string) (V, error)) (*fileReloader[V], error) {
+	r := &fileReloader[V]{
+		files: files,
+		load:  load,
+	}
+
+	var err error
+	r.modTimes = r.stat()
+	r.checked = time.Now()
+	r.value, err = load(files)
+
+	return r, err
+}
+
+// get returns the current value, reloading it if a file has changed since the last check
+func (r *fileReloader[V]) get() V {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.files) == 0 || time.Since(r.checked) < tlsReloadCheckInterval {
+		return r.value
+	}
+	r.checked = time.Now()
+
+	modTimes := r.stat()
+	changed := false
+	for i := range modTimes {
+		if !modTimes[i].Equal(r.modTimes[i]) {
+			changed = true
+		}
+	}
+
+	if changed {
+		value, err := r.load(r.files)
+		if err != nil {
+			slog.Error("https: failed to reload TLS files", "files", r.files, "err", err)
+			return r.value
+		}
+		r.value = value
+		r.modTimes = modTimes
+		slog.Info("https: reloaded TLS files", "files", r.files)
+	}
+
+	return r.value
+}
+
+// stat returns the modification time of each file, zero if the file can't be read
+func (r *fileReloader[V]) stat() []time.Time {
+	modTimes := make([]time.Time, len(r.files))
+	for i, file := range r.files {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+
+	return modTimes
+}
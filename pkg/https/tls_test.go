@@ -0,0 +1,301 @@
+package https
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestNewHostTLSConfigs_RootCAAndPins(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cert := server.Certificate()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	pin := "sha256/" + base64.StdEncoding.EncodeToString(hash[:])
+	host := mustHostname(t, server.URL)
+	wrongPin := "sha256/" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	tests := []struct {
+		name    string
+		options []func(cfg *TLSOptions)
+		wantErr bool
+	}{
+		{"unknown CA", nil, true},
+		{"custom CA", []func(cfg *TLSOptions){WithRootCAPEM(caPEM)}, false},
+		{"matching pin", []func(cfg *TLSOptions){WithRootCAPEM(caPEM), WithSPKIPins(host, pin)}, false},
+		{"wrong pin", []func(cfg *TLSOptions){WithRootCAPEM(caPEM), WithSPKIPins(host, wrongPin)}, true},
+		{"pin for other host", []func(cfg *TLSOptions){WithRootCAPEM(caPEM), WithSPKIPins("example.com", wrongPin)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, err := newHostTLSConfigs(tt.options...)
+			if err != nil {
+				t.Fatalf("newHostTLSConfigs returned error: %v", err)
+			}
+
+			client := &fasthttp.Client{}
+			configs.apply(client)
+			code, _, err := client.Get(nil, server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Get error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && code != fasthttp.StatusOK {
+				t.Errorf("Expected status 200, got %d", code)
+			}
+		})
+	}
+}
+
+func TestNewHostTLSConfigs_InvalidCA(t *testing.T) {
+	if _, err := newHostTLSConfigs(WithRootCAPEM([]byte("not a certificate"))); err == nil {
+		t.Error("newHostTLSConfigs should return error for an invalid CA bundle")
+	}
+
+	if _, err := newHostTLSConfigs(WithClientCertFile("missing.crt", "missing.key")); err == nil {
+		t.Error("newHostTLSConfigs should return error for missing certificate files")
+	}
+}
+
+func TestNewHostTLSConfigs_InvalidPin(t *testing.T) {
+	for _, pin := range []string{"sha256/AAAA", "sha256/not base64!"} {
+		if _, err := newHostTLSConfigs(WithSPKIPins("example.com", pin)); err == nil {
+			t.Errorf("newHostTLSConfigs should return error for the pin %q", pin)
+		}
+	}
+}
+
+func TestNewHostTLSConfigs_AppendedPinnedCert(t *testing.T) {
+	// The server is trusted, it appends the pinned certificate of the partner to its own chain
+	ca := newTestCert(t, nil)
+	leaf, partner := newTestCert(t, ca), newTestCert(t, nil)
+	server := newTestTLSServer(t, tls.Certificate{
+		Certificate: [][]byte{leaf.cert.Raw, partner.cert.Raw},
+		PrivateKey:  leaf.key,
+	}, nil)
+	host := mustHostname(t, server.URL)
+
+	hash := sha256.Sum256(partner.cert.RawSubjectPublicKeyInfo)
+	pin := WithSPKIPins(host, base64.StdEncoding.EncodeToString(hash[:]))
+
+	t.Run("custom roots", func(t *testing.T) {
+		configs, err := newHostTLSConfigs(WithRootCAPEM(ca.certPEM), pin)
+		if err != nil {
+			t.Fatalf("newHostTLSConfigs returned error: %v", err)
+		}
+		client := &fasthttp.Client{}
+		configs.apply(client)
+		if _, _, err = client.Get(nil, server.URL); err == nil {
+			t.Error("Expected the appended pinned certificate to be rejected")
+		}
+	})
+
+	t.Run("default verification", func(t *testing.T) {
+		configs, err := newHostTLSConfigs(pin)
+		if err != nil {
+			t.Fatalf("newHostTLSConfigs returned error: %v", err)
+		}
+		// The CA stands for a public CA of the system roots
+		configs.base.RootCAs = x509.NewCertPool()
+		configs.base.RootCAs.AddCert(ca.cert)
+		client := &fasthttp.Client{}
+		configs.apply(client)
+		if _, _, err = client.Get(nil, server.URL); err == nil {
+			t.Error("Expected the appended pinned certificate to be rejected")
+		}
+	})
+}
+
+func TestNewHostTLSConfigs_ClientCert(t *testing.T) {
+	ca := newTestCert(t, nil)
+	client := newTestCert(t, ca)
+	server := newTestTLSServer(t, newTestCert(t, ca).tlsCert(t), ca.cert)
+
+	tests := []struct {
+		name    string
+		options []func(cfg *TLSOptions)
+		wantErr bool
+	}{
+		{"no client certificate", nil, true},
+		{"client certificate", []func(cfg *TLSOptions){WithClientCertPEM(client.certPEM, client.keyPEM)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, err := newHostTLSConfigs(append(tt.options, WithRootCAPEM(ca.certPEM))...)
+			if err != nil {
+				t.Fatalf("newHostTLSConfigs returned error: %v", err)
+			}
+
+			client := &fasthttp.Client{}
+			configs.apply(client)
+			if _, _, err = client.Get(nil, server.URL); (err != nil) != tt.wantErr {
+				t.Errorf("Get error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewHostTLSConfigs_Reload(t *testing.T) {
+	previous := tlsReloadCheckInterval
+	tlsReloadCheckInterval = 10 * time.Millisecond
+	t.Cleanup(func() { tlsReloadCheckInterval = previous })
+
+	oldCA, newCA := newTestCert(t, nil), newTestCert(t, nil)
+	oldClient, newClient := newTestCert(t, oldCA), newTestCert(t, newCA)
+	// The server is signed by the new CA and requires a client certificate of the new CA
+	server := newTestTLSServer(t, newTestCert(t, newCA).tlsCert(t), newCA.cert)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	writeFiles := func(modTime time.Time, ca, client *testCert) {
+		for file, data := range map[string][]byte{caFile: ca.certPEM, certFile: client.certPEM, keyFile: client.keyPEM} {
+			if err := os.WriteFile(file, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			// The modification time changes even on a coarse file system clock
+			if err := os.Chtimes(file, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	writeFiles(time.Now().Add(-time.Hour), oldCA, oldClient)
+
+	configs, err := newHostTLSConfigs(WithRootCAFile(caFile), WithClientCertFile(certFile, keyFile))
+	if err != nil {
+		t.Fatalf("newHostTLSConfigs returned error: %v", err)
+	}
+	client := &fasthttp.Client{}
+	configs.apply(client)
+	if _, _, err = client.Get(nil, server.URL); err == nil {
+		t.Fatal("Expected the old CA and client certificate to be rejected")
+	}
+
+	writeFiles(time.Now(), newCA, newClient)
+	time.Sleep(2 * tlsReloadCheckInterval)
+	if _, _, err = client.Get(nil, server.URL); err != nil {
+		t.Errorf("Expected the reloaded CA and client certificate, got %v", err)
+	}
+}
+
+func TestHostTLSConfigs_ApplyKeepsConfigureClient(t *testing.T) {
+	configs, err := newHostTLSConfigs(WithSPKIPins("example.com", base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))))
+	if err != nil {
+		t.Fatalf("newHostTLSConfigs returned error: %v", err)
+	}
+
+	called := false
+	client := &fasthttp.Client{ConfigureClient: func(hc *fasthttp.HostClient) error {
+		called = true
+		return nil
+	}}
+	configs.apply(client)
+
+	hc := &fasthttp.HostClient{Addr: "example.com:443"}
+	if err = client.ConfigureClient(hc); err != nil {
+		t.Fatalf("ConfigureClient returned error: %v", err)
+	}
+	if !called || hc.TLSConfig == nil || hc.TLSConfig.VerifyConnection == nil {
+		t.Errorf("Expected both hooks to configure the host client, called %v, TLS config %v", called, hc.TLSConfig)
+	}
+}
+
+// testCert is a certificate with its key, for the TLS tests
+type testCert struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	keyPEM  []byte
+	key     *ecdsa.PrivateKey
+}
+
+// newTestCert returns a CA if parent is nil, else a certificate for 127.0.0.1 issued by parent
+func newTestCert(t *testing.T, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "test " + serial.String()},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		key:     key,
+	}
+}
+
+// tlsCert returns the certificate for a tls.Config
+func (c *testCert) tlsCert(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// newTestTLSServer starts a TLS server with the certificate, it requires a client certificate of clientCA if set
+func newTestTLSServer(t *testing.T, cert tls.Certificate, clientCA *x509.Certificate) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCA != nil {
+		server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+		server.TLS.ClientCAs = x509.NewCertPool()
+		server.TLS.ClientCAs.AddCert(clientCA)
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func mustHostname(t *testing.T, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,346 @@
+package queue
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gocraft/work"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// adminPageSize is the number of jobs per page, as gocraft/work pages.
+	adminPageSize = 20
+	// adminScanSize is the number of jobs read per round trip from a set of the shared namespace.
+	adminScanSize = 1000
+)
+
+// Job is a dead, retry or scheduled job of a queue, with its decoded payload.
+type Job[T any] struct {
+	ID         string    // Job ID, returned by DispatchIn/DispatchAt
+	Queue      string    // Name of the queue
+	Payload    *T        // Decoded payload, nil if it can't be decoded (see DecodeErr)
+	DecodeErr  error     // Error decoding the payload
+	EnqueuedAt time.Time // First enqueue
+	Fails      int64     // Number of failed attempts
+	LastErr    string    // Error returned by the last attempt
+	FailedAt   time.Time // Last failed attempt, zero for a scheduled job
+	At         time.Time // Died at (dead), next attempt (retry) or run at (scheduled)
+}
+
+// Admin inspects and recovers the dead, retry and scheduled jobs of a queue.
+// Built on the gocraft/work client, the methods return ErrNotSupported with the Watermill provider.
+//
+// In the shared namespace (default, see workNamespace) the dead, retry and scheduled sets hold the jobs of every queue,
+// the Admin reads them in full and only lists and changes the jobs of its queue.
+//
+// WHY typed?
+//   - Ops tooling shows the payloads, not JSON strings
+//   - A queue has one payload type, like its Worker
+//
+// Example:
+//
+//	admin := queue.NewAdmin[MyPayload]("my-queue")
+//	dead, total, err := admin.DeadJobs(1)
+//	for _, job := range dead {
+//	    slog.Info("dead job", "id", job.ID, "err", job.LastErr, "payload", job.Payload)
+//	}
+//	err = admin.RetryDead(dead...)
+type Admin[T any] struct {
+	queueName string
+	client    *work.Client
+	shared    bool // The namespace is shared with other queues, see workNamespace
+}
+
+// NewAdmin creates a new Admin for the jobs of the queue.
+func NewAdmin[T any](queueName string) *Admin[T] {
+	admin := &Admin[T]{queueName: queueName, shared: !namespacePerQueue}
+	if poolProvider() != poolWaterMill {
+		admin.client = work.NewClient(workNamespace(queueName), instancePool())
+	}
+	return admin
+}
+
+// DeadJobs returns a page (from 1, 20 jobs per page) of the jobs that failed MaxFails times,
+// oldest first, with the total number of dead jobs.
+func (a *Admin[T]) DeadJobs(page uint) ([]*Job[T], int64, error) {
+	if a.client == nil {
+		return nil, 0, ErrNotSupported
+	}
+	if a.shared {
+		return a.sharedJobs("dead", page)
+	}
+
+	dead, total, err := a.client.DeadJobs(page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jobs := make([]*Job[T], 0, len(dead))
+	for _, job := range dead {
+		jobs = append(jobs, a.job(job.Job, job.DiedAt))
+	}
+	return jobs, total, nil
+}
+
+// RetryJobs returns a page (from 1, 20 jobs per page) of the failed jobs waiting for a retry,
+// with the total number of retry jobs.
+func (a *Admin[T]) RetryJobs(page uint) ([]*Job[T], int64, error) {
+	if a.client == nil {
+		return nil, 0, ErrNotSupported
+	}
+	if a.shared {
+		return a.sharedJobs("retry", page)
+	}
+
+	retry, total, err := a.client.RetryJobs(page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jobs := make([]*Job[T], 0, len(retry))
+	for _, job := range retry {
+		jobs = append(jobs, a.job(job.Job, job.RetryAt))
+	}
+	return jobs, total, nil
+}
+
+// ScheduledJobs returns a page (from 1, 20 jobs per page) of the jobs dispatched with DispatchIn/DispatchAt,
+// with the total number of scheduled jobs.
+func (a *Admin[T]) ScheduledJobs(page uint) ([]*Job[T], int64, error) {
+	if a.client == nil {
+		return nil, 0, ErrNotSupported
+	}
+	if a.shared {
+		return a.sharedJobs("scheduled", page)
+	}
+
+	scheduled, total, err := a.client.ScheduledJobs(page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jobs := make([]*Job[T], 0, len(scheduled))
+	for _, job := range scheduled {
+		jobs = append(jobs, a.job(job.Job, job.RunAt))
+	}
+	return jobs, total, nil
+}
+
+// RetryDead moves the dead jobs back to the queue, their fails are reset.
+func (a *Admin[T]) RetryDead(jobs ...*Job[T]) error {
+	if a.client == nil {
+		return ErrNotSupported
+	}
+
+	for _, job := range jobs {
+		if err := a.client.RetryDeadJob(job.At.Unix(), job.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetryAllDead moves all the dead jobs back to the queue.
+func (a *Admin[T]) RetryAllDead() error {
+	if a.client == nil {
+		return ErrNotSupported
+	}
+	if a.shared {
+		jobs, err := a.sharedAll("dead")
+		if err != nil {
+			return err
+		}
+		return a.RetryDead(jobs...)
+	}
+	return a.client.RetryAllDeadJobs()
+}
+
+// DeleteDead deletes the dead jobs.
+func (a *Admin[T]) DeleteDead(jobs ...*Job[T]) error {
+	if a.client == nil {
+		return ErrNotSupported
+	}
+
+	for _, job := range jobs {
+		if err := a.client.DeleteDeadJob(job.At.Unix(), job.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteAllDead deletes all the dead jobs.
+func (a *Admin[T]) DeleteAllDead() error {
+	if a.client == nil {
+		return ErrNotSupported
+	}
+	if a.shared {
+		jobs, err := a.sharedAll("dead")
+		if err != nil {
+			return err
+		}
+		return a.DeleteDead(jobs...)
+	}
+	return a.client.DeleteAllDeadJobs()
+}
+
+// DeleteRetry deletes the jobs waiting for a retry, they are not retried.
+func (a *Admin[T]) DeleteRetry(jobs ...*Job[T]) error {
+	if a.client == nil {
+		return ErrNotSupported
+	}
+
+	for _, job := range jobs {
+		if err := a.client.DeleteRetryJob(job.At.Unix(), job.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteScheduled deletes the scheduled jobs, they are cancelled.
+func (a *Admin[T]) DeleteScheduled(jobs ...*Job[T]) error {
+	if a.client == nil {
+		return ErrNotSupported
+	}
+
+	for _, job := range jobs {
+		if err := a.client.DeleteScheduledJob(job.At.Unix(), job.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeDead deletes the jobs that died more than age ago, it returns the number of deleted jobs.
+//
+// Example:
+//
+//	// Keep a week of dead jobs
+//	deleted, err := admin.PurgeDead(7 * 24 * time.Hour)
+func (a *Admin[T]) PurgeDead(age time.Duration) (int, error) {
+	if a.client == nil {
+		return 0, ErrNotSupported
+	}
+
+	cutoff := time.Now().Add(-age)
+	deleted := 0
+	if a.shared {
+		jobs, err := a.sharedAll("dead")
+		if err != nil {
+			return 0, err
+		}
+		for _, job := range jobs {
+			if !job.At.Before(cutoff) {
+				break
+			}
+			if err = a.DeleteDead(job); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		return deleted, nil
+	}
+
+	for {
+		// The dead jobs are sorted by died at, the first page holds the oldest ones
+		jobs, _, err := a.DeadJobs(1)
+		if err != nil {
+			return deleted, err
+		}
+
+		for _, job := range jobs {
+			if !job.At.Before(cutoff) {
+				return deleted, nil
+			}
+			if err = a.DeleteDead(job); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		if len(jobs) == 0 {
+			return deleted, nil
+		}
+	}
+}
+
+// sharedJobs returns a page (from 1) of the jobs of the queue in a set of the shared namespace, with their total
+func (a *Admin[T]) sharedJobs(set string, page uint) ([]*Job[T], int64, error) {
+	from := int64(max(page, 1)-1) * adminPageSize
+	var (
+		jobs  []*Job[T]
+		total int64
+	)
+	err := a.sharedScan(set, func(job *work.Job, at int64) {
+		if total >= from && total < from+adminPageSize {
+			jobs = append(jobs, a.job(job, at))
+		}
+		total++
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+// sharedAll returns all the jobs of the queue in a set of the shared namespace, oldest first
+func (a *Admin[T]) sharedAll(set string) ([]*Job[T], error) {
+	var jobs []*Job[T]
+	err := a.sharedScan(set, func(job *work.Job, at int64) {
+		jobs = append(jobs, a.job(job, at))
+	})
+	return jobs, err
+}
+
+// sharedScan calls fn for each job of the queue in a set (dead, retry or scheduled) of the shared namespace,
+// sorted by time like the gocraft/work pages
+func (a *Admin[T]) sharedScan(set string, fn func(job *work.Job, at int64)) error {
+	conn := instancePool().Get()
+	defer conn.Close()
+
+	key := workKeyPrefix(workNamespace(a.queueName)) + set
+	for start := 0; ; start += adminScanSize {
+		values, err := redis.Values(conn.Do("ZRANGE", key, start, start+adminScanSize-1, "WITHSCORES"))
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i+1 < len(values); i += 2 {
+			raw, _ := redis.Bytes(values[i], nil)
+			at, _ := redis.Float64(values[i+1], nil)
+			job := &work.Job{}
+			if err = json.Unmarshal(raw, job); err != nil || job.Name != a.queueName {
+				continue
+			}
+			fn(job, int64(at))
+		}
+		if len(values) < 2*adminScanSize {
+			return nil
+		}
+	}
+}
+
+// job converts a gocraft/work job and decodes its payload
+func (a *Admin[T]) job(job *work.Job, at int64) *Job[T] {
+	j := &Job[T]{
+		ID:         job.ID,
+		Queue:      a.queueName,
+		EnqueuedAt: unixTime(job.EnqueuedAt),
+		Fails:      job.Fails,
+		LastErr:    job.LastErr,
+		FailedAt:   unixTime(job.FailedAt),
+		At:         unixTime(at),
+	}
+
+	j.Payload, j.DecodeErr = decodePayload[T](job.ArgString("payload"), job.ArgString("encoding"))
+	return j
+}
+
+// unixTime converts Unix seconds to a time, zero stays zero
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
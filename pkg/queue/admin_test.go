@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// adminQueue returns a queue name unique per run
+func adminQueue(t *testing.T) string {
+	return t.Name() + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// addDeadJob adds a job to the dead set as gocraft/work does
+func addDeadJob(t *testing.T, queueName, id string, diedAt time.Time, payload string) {
+	t.Helper()
+	conn := instancePool().Get()
+	defer conn.Close()
+
+	job := fmt.Sprintf(`{"name":%q,"id":%q,"t":%d,"args":{"payload":%q},"fails":1,"err":"boom","failed_at":%d}`,
+		queueName, id, diedAt.Unix(), payload, diedAt.Unix())
+	if _, err := conn.Do("ZADD", workKeyPrefix(workNamespace(queueName))+"dead", diedAt.Unix(), job); err != nil {
+		t.Fatalf("ZADD returned error: %v", err)
+	}
+}
+
+func TestAdmin_DeadJobs(t *testing.T) {
+	queueName := adminQueue(t)
+	q := NewQueue[TestPayload](queueName)
+	q.WithData(&TestPayload{ID: 1, Name: "user"})
+	if err := q.Dispatch(); err != nil {
+		t.Fatalf("Dispatch returned error: %v", err)
+	}
+
+	var calls atomic.Int32
+	runWorker(t, NewWorker[TestPayload](queueName, WithMaxFails(1)), func(ctx context.Context, data *TestPayload) error {
+		if calls.Add(1) == 1 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	admin := NewAdmin[TestPayload](queueName)
+	var dead []*Job[TestPayload]
+	waitFor(t, func() bool {
+		dead, _, _ = admin.DeadJobs(1)
+		return len(dead) == 1
+	})
+
+	job := dead[0]
+	if job.Payload == nil || job.Payload.ID != 1 || job.Payload.Name != "user" {
+		t.Errorf("Expected the decoded payload, got %+v (%v)", job.Payload, job.DecodeErr)
+	}
+	if job.LastErr != "boom" || job.Fails != 1 || job.Queue != queueName {
+		t.Errorf("Expected the error of the job, got %+v", job)
+	}
+	if job.At.IsZero() || job.EnqueuedAt.IsZero() {
+		t.Errorf("Expected the times of the job, got %+v", job)
+	}
+
+	if err := admin.RetryDead(job); err != nil {
+		t.Fatalf("RetryDead returned error: %v", err)
+	}
+	waitFor(t, func() bool { return calls.Load() == 2 })
+
+	if _, total, _ := admin.DeadJobs(1); total != 0 {
+		t.Errorf("Expected no dead job after retry, got %d", total)
+	}
+}
+
+func TestAdmin_PurgeDead(t *testing.T) {
+	queueName := adminQueue(t)
+	now := time.Now()
+	addDeadJob(t, queueName, "old-1", now.Add(-48*time.Hour), `{"id":1}`)
+	addDeadJob(t, queueName, "old-2", now.Add(-25*time.Hour), `{"id":2}`)
+	addDeadJob(t, queueName, "recent", now.Add(-time.Hour), `{"id":3}`)
+
+	admin := NewAdmin[TestPayload](queueName)
+	deleted, err := admin.PurgeDead(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeDead returned error: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deleted jobs, got %d", deleted)
+	}
+
+	dead, total, err := admin.DeadJobs(1)
+	if err != nil {
+		t.Fatalf("DeadJobs returned error: %v", err)
+	}
+	if total != 1 || dead[0].ID != "recent" || dead[0].Payload.ID != 3 {
+		t.Errorf("Expected only the recent job, got %d %+v", total, dead)
+	}
+
+	if err = admin.DeleteAllDead(); err != nil {
+		t.Fatalf("DeleteAllDead returned error: %v", err)
+	}
+	if _, total, _ = admin.DeadJobs(1); total != 0 {
+		t.Errorf("Expected no dead job, got %d", total)
+	}
+}
+
+func TestAdmin_DecodeError(t *testing.T) {
+	queueName := adminQueue(t)
+	addDeadJob(t, queueName, "invalid", time.Now(), `{"id":"not a number"}`)
+
+	dead, _, err := NewAdmin[TestPayload](queueName).DeadJobs(1)
+	if err != nil {
+		t.Fatalf("DeadJobs returned error: %v", err)
+	}
+	if len(dead) != 1 || dead[0].Payload != nil || dead[0].DecodeErr == nil {
+		t.Errorf("Expected a decode error, got %+v", dead)
+	}
+}
+
+func TestAdmin_ScheduledJobs(t *testing.T) {
+	queueName := adminQueue(t)
+	q := NewQueue[TestPayload](queueName)
+	q.WithData(&TestPayload{ID: 1})
+	id, err := q.DispatchIn(time.Hour)
+	if err != nil {
+		t.Fatalf("DispatchIn returned error: %v", err)
+	}
+
+	admin := NewAdmin[TestPayload](queueName)
+	scheduled, total, err := admin.ScheduledJobs(1)
+	if err != nil {
+		t.Fatalf("ScheduledJobs returned error: %v", err)
+	}
+	if total != 1 || scheduled[0].ID != id || scheduled[0].At.Before(time.Now().Add(59*time.Minute)) {
+		t.Fatalf("Expected the scheduled job %s in an hour, got %+v", id, scheduled)
+	}
+
+	if err = admin.DeleteScheduled(scheduled[0]); err != nil {
+		t.Fatalf("DeleteScheduled returned error: %v", err)
+	}
+	if _, total, _ = admin.ScheduledJobs(1); total != 0 {
+		t.Errorf("Expected the scheduled job to be cancelled, got %d", total)
+	}
+}
+
+func TestAdmin_Watermill(t *testing.T) {
+	previous := poolProvider
+	poolProvider = func() string { return poolWaterMill }
+	t.Cleanup(func() { poolProvider = previous })
+
+	if _, _, err := NewAdmin[TestPayload]("queue").DeadJobs(1); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/gocraft/work"
+	"github.com/gomodule/redigo/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// defaultBatchSize is the number of jobs DispatchBatch sends to Redis per round trip.
+const defaultBatchSize = 500
+
+// workEnqueueUnique is the gocraft/work script of EnqueueUnique, the unique key expires after a day.
+// KEYS[1] = job queue, KEYS[2] = unique key, ARGV[1] = job
+var workEnqueueUnique = redis.NewScript(2, `
+if redis.call('set', KEYS[2], '1', 'NX', 'EX', '86400') then
+  redis.call('lpush', KEYS[1], ARGV[1])
+  return 'ok'
+end
+return 'dup'
+`)
+
+// BatchResult is the result of a job of DispatchBatch, in the order of the items.
+type BatchResult struct {
+	ID  string // Job ID, empty if the job was not added (error or duplicate unique job)
+	Err error  // Error encoding or enqueuing the job
+}
+
+// batchJob is an encoded item of a batch
+type batchJob struct {
+	index    int
+	payload  string
+	encoding string
+}
+
+// DispatchBatch adds a job per item to the queue.
+// The jobs are sent to Redis in chunks (see WithBatchSize), one round trip per chunk.
+// It returns a result per item, and an error if any job failed.
+//
+// WHY?
+//   - Fan-out (e.g., one job per product of a 50k catalog) with Dispatch is one round trip per job
+//   - The jobs have the format of Dispatch, the workers consume them unchanged
+//
+// Example:
+//
+//	results, err := q.DispatchBatch(products)
+//	if err != nil {
+//	    for i, result := range results {
+//	        if result.Err != nil {
+//	            slog.Error("product not queued", "product", products[i].ID, "err", result.Err)
+//	        }
+//	    }
+//	}
+func (q *Queue[T]) DispatchBatch(items []*T) ([]BatchResult, error) {
+	return q.dispatchBatch(items, false)
+}
+
+// DispatchUniqueBatch adds a unique job per item to the queue, see DispatchUnique.
+// The result ID is empty for an item whose job is already waiting, or repeated in the batch.
+func (q *Queue[T]) DispatchUniqueBatch(items []*T) ([]BatchResult, error) {
+	return q.dispatchBatch(items, true)
+}
+
+// dispatchBatch encodes the items and sends their jobs in chunks
+func (q *Queue[T]) dispatchBatch(items []*T, unique bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(items))
+	jobs := make([]batchJob, 0, len(items))
+	for i, item := range items {
+		payload, encoding, err := encodePayload(q.options.Codec, q.options.CompressAbove, item)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		jobs = append(jobs, batchJob{index: i, payload: payload, encoding: encoding})
+	}
+
+	size := q.options.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	for start := 0; start < len(jobs); start += size {
+		q.task.dispatchChunk(q.queueName, jobs[start:min(start+size, len(jobs))], unique, results)
+	}
+
+	failed := 0
+	var first error
+	for _, result := range results {
+		if result.Err != nil {
+			if first == nil {
+				first = result.Err
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("queue: %d of %d jobs failed: %w", failed, len(items), first)
+	}
+	return results, nil
+}
+
+// dispatchChunk sends the jobs in one pipeline and sets their results
+func (t *Task) dispatchChunk(queueName string, jobs []batchJob, unique bool, results []BatchResult) {
+	if t.publisher != nil {
+		t.publishWatermillChunk(queueName, jobs, unique, results)
+		return
+	}
+
+	setErr := func(err error) {
+		for _, job := range jobs {
+			results[job.index] = BatchResult{Err: err}
+		}
+	}
+
+	conn := instancePool().Get()
+	defer conn.Close()
+
+	// The same commands as the gocraft/work Enqueuer, pipelined
+	prefix := workKeyPrefix(workNamespace(queueName))
+	jobsKey := prefix + "jobs:" + queueName
+	if err := conn.Send("SADD", prefix+"known_jobs", queueName); err != nil {
+		setErr(err)
+		return
+	}
+
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		args := jobArgs(job.payload, job.encoding)
+		ids[i] = newJobID()
+		raw, err := json.Marshal(&work.Job{
+			Name:       queueName,
+			ID:         ids[i],
+			EnqueuedAt: time.Now().Unix(),
+			Args:       args,
+			Unique:     unique,
+		})
+		if err != nil {
+			setErr(err)
+			return
+		}
+
+		if unique {
+			key, err := workUniqueKey(prefix, queueName, args)
+			if err != nil {
+				setErr(err)
+				return
+			}
+			err = workEnqueueUnique.Send(conn, jobsKey, key, raw)
+		} else {
+			err = conn.Send("LPUSH", jobsKey, raw)
+		}
+		if err != nil {
+			setErr(err)
+			return
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		setErr(err)
+		return
+	}
+	if _, err := conn.Receive(); err != nil {
+		setErr(err)
+		return
+	}
+	for i, job := range jobs {
+		reply, err := conn.Receive()
+		if unique && err == nil {
+			reply, err = redis.String(reply, err)
+		}
+		switch {
+		case err != nil:
+			results[job.index].Err = err
+		case reply == "dup":
+			// A unique job with the same payload is waiting
+		default:
+			results[job.index].ID = ids[i]
+		}
+	}
+}
+
+// publishWatermillChunk adds the messages to the stream of the queue in one pipeline,
+// in the format of the Watermill publisher.
+// The unique locks are taken first, in another pipeline, and released for the messages not added.
+func (t *Task) publishWatermillChunk(queueName string, jobs []batchJob, unique bool, results []BatchResult) {
+	ctx := context.Background()
+	client := instanceClient()
+
+	msgs := make([]*message.Message, len(jobs))
+	for i, job := range jobs {
+		msgs[i] = message.NewMessage(watermill.NewUUID(), []byte(job.payload))
+		msgs[i].Metadata.Set("enqueued_at", strconv.FormatInt(time.Now().Unix(), 10))
+		if job.encoding != "" {
+			msgs[i].Metadata.Set("encoding", job.encoding)
+		}
+	}
+
+	locked := make([]bool, len(jobs))
+	if unique {
+		pipe := client.Pipeline()
+		cmds := make([]*goredis.BoolCmd, len(jobs))
+		for i, job := range jobs {
+			key := watermillUniqueKey(queueName, job.payload)
+			msgs[i].Metadata.Set("unique", key)
+			cmds[i] = pipe.SetNX(ctx, key, msgs[i].UUID, watermillUniqueTTL)
+		}
+		_, _ = pipe.Exec(ctx) // The errors are on the commands
+		for i, cmd := range cmds {
+			if err := cmd.Err(); err != nil {
+				results[jobs[i].index].Err = err
+			}
+			locked[i] = cmd.Val()
+		}
+	}
+
+	pipe := client.Pipeline()
+	cmds := make([]*goredis.StringCmd, len(jobs))
+	topic := watermillTopic(queueName)
+	for i, job := range jobs {
+		if unique && !locked[i] {
+			continue
+		}
+		values, err := redisstream.DefaultMarshallerUnmarshaller{}.Marshal(topic, msgs[i])
+		if err != nil {
+			results[job.index].Err = err
+			if unique {
+				releaseUnique(queueName, msgs[i].Metadata.Get("unique"))
+			}
+			continue
+		}
+		cmds[i] = pipe.XAdd(ctx, &goredis.XAddArgs{Stream: topic, Values: values})
+	}
+	if pipe.Len() == 0 {
+		return
+	}
+	_, _ = pipe.Exec(ctx)
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		if err := cmd.Err(); err != nil {
+			results[jobs[i].index].Err = err
+			if unique {
+				releaseUnique(queueName, msgs[i].Metadata.Get("unique"))
+			}
+			continue
+		}
+		results[jobs[i].index].ID = msgs[i].UUID
+	}
+}
+
+// newJobID returns a job ID in the format of gocraft/work
+func newJobID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// workUniqueKey returns the gocraft/work unique key of a job, as EnqueueUnique builds it
+func workUniqueKey(prefix, queueName string, args work.Q) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteString(prefix + "unique:" + queueName + ":")
+	if err := json.NewEncoder(&buf).Encode(args); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProvider_DispatchBatch(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		items := make([]*TestPayload, 5)
+		for i := range items {
+			items[i] = &TestPayload{ID: i, Name: "user"}
+		}
+
+		q := NewQueue[TestPayload](queueName, WithBatchSize(2))
+		results, err := q.DispatchBatch(items)
+		if err != nil {
+			t.Fatalf("DispatchBatch returned error: %v", err)
+		}
+		ids := map[string]bool{}
+		for _, result := range results {
+			if result.ID == "" || result.Err != nil {
+				t.Errorf("Expected a job ID, got %+v", result)
+			}
+			ids[result.ID] = true
+		}
+		if len(ids) != len(items) {
+			t.Errorf("Expected %d distinct job IDs, got %d", len(items), len(ids))
+		}
+
+		received := make(chan *JobInfo, len(items))
+		runWorker(t, NewWorker[TestPayload](queueName), func(ctx context.Context, data *TestPayload) error {
+			job, _ := JobFromContext(ctx)
+			received <- job
+			return nil
+		})
+
+		for range items {
+			select {
+			case job := <-received:
+				if !ids[job.ID] {
+					t.Errorf("Unexpected job ID %q", job.ID)
+				}
+			case <-time.After(15 * time.Second):
+				t.Fatal("timeout")
+			}
+		}
+	})
+}
+
+func TestProvider_DispatchUniqueBatch(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		// A job dispatched one by one is a duplicate of the batch item
+		q := NewQueue[TestPayload](queueName)
+		q.WithData(&TestPayload{ID: 1})
+		if err := q.DispatchUnique(); err != nil {
+			t.Fatalf("DispatchUnique returned error: %v", err)
+		}
+
+		results, err := q.DispatchUniqueBatch([]*TestPayload{{ID: 1}, {ID: 2}, {ID: 2}, {ID: 3}})
+		if err != nil {
+			t.Fatalf("DispatchUniqueBatch returned error: %v", err)
+		}
+		for i, added := range []bool{false, true, false, true} {
+			if (results[i].ID != "") != added {
+				t.Errorf("Item %d: expected added %v, got %+v", i, added, results[i])
+			}
+		}
+
+		if poolProvider() == poolWaterMill {
+			payload, _, _ := encodePayload(JSONCodec, 0, &TestPayload{ID: 2})
+			if ttl := instanceClient().TTL(context.Background(), watermillUniqueKey(queueName, payload)).Val(); ttl <= 0 {
+				t.Errorf("Expected the unique lock to expire, got TTL %v", ttl)
+			}
+		}
+
+		received := make(chan int, 4)
+		runWorker(t, NewWorker[TestPayload](queueName), func(ctx context.Context, data *TestPayload) error {
+			received <- data.ID
+			return nil
+		})
+
+		seen := map[int]int{}
+		for range 3 {
+			select {
+			case id := <-received:
+				seen[id]++
+			case <-time.After(15 * time.Second):
+				t.Fatal("timeout")
+			}
+		}
+		select {
+		case id := <-received:
+			t.Errorf("Unexpected duplicate job %d", id)
+		case <-time.After(500 * time.Millisecond):
+		}
+		if seen[1] != 1 || seen[2] != 1 || seen[3] != 1 {
+			t.Errorf("Expected each job once, got %v", seen)
+		}
+	})
+}
+
+func TestQueue_DispatchBatchMarshalError(t *testing.T) {
+	type invalid struct{ C chan int }
+
+	q := NewQueue[invalid]("invalid")
+	results, err := q.DispatchBatch([]*invalid{{C: make(chan int)}})
+	if err == nil || results[0].Err == nil || results[0].ID != "" {
+		t.Errorf("Expected the marshal error, got %v %+v", err, results)
+	}
+}
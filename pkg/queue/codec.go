@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack"
+	"google.golang.org/protobuf/proto"
+)
+
+// compressionGzip is the encoding suffix of the gzip compressed payloads
+const compressionGzip = "+gzip"
+
+var (
+	// JSONCodec encodes the payloads with encoding/json (default).
+	JSONCodec Codec = jsonCodec{}
+	// MsgpackCodec encodes the payloads with MessagePack, smaller and faster than JSON.
+	MsgpackCodec Codec = msgpackCodec{}
+	// ProtobufCodec encodes the payloads with Protocol Buffers, *T must be a proto.Message.
+	ProtobufCodec Codec = protobufCodec{}
+
+	// codecs are the codecs the workers decode, by name.
+	codecs = map[string]Codec{
+		JSONCodec.Name():     JSONCodec,
+		MsgpackCodec.Name():  MsgpackCodec,
+		ProtobufCodec.Name(): ProtobufCodec,
+	}
+	// codecsMu protects codecs.
+	codecsMu sync.RWMutex
+)
+
+// Codec encodes the job payloads.
+// The name is stored with the job, so that a worker decodes the jobs of any registered codec.
+type Codec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// RegisterCodec registers a custom codec, for the workers to decode its jobs.
+// The built-in codecs are registered.
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[codec.Name()] = codec
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string                       { return "msgpack" }
+func (msgpackCodec) Marshal(v any) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return "protobuf" }
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("queue: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("queue: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// encodePayload encodes the payload with the codec, compressed with gzip above the threshold (0 to disable).
+// It returns the payload and its encoding: "<codec>[+gzip]".
+//
+// WHY an encoding?
+//   - The worker decodes the jobs dispatched before a codec change
+//   - Uncompressed JSON has no encoding and a plain payload, the format of the jobs before codecs,
+//     so that the workers not upgraded yet still decode them
+//   - The other payloads are base64, the gocraft/work job args are JSON strings
+func encodePayload(codec Codec, threshold int, v any) (string, string, error) {
+	b, err := codec.Marshal(v)
+	if err != nil {
+		return "", "", fmt.Errorf("queue: failed to encode payload with %s: %w", codec.Name(), err)
+	}
+
+	encoding := codec.Name()
+	if threshold > 0 && len(b) > threshold {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err = zw.Write(b); err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("queue: failed to compress payload: %w", err)
+		}
+		b, encoding = buf.Bytes(), encoding+compressionGzip
+	}
+
+	if encoding == JSONCodec.Name() {
+		return string(b), "", nil
+	}
+	return base64.StdEncoding.EncodeToString(b), encoding, nil
+}
+
+// decodePayload decodes a payload encoded by encodePayload, an empty encoding is plain JSON.
+func decodePayload[T any](payload, encoding string) (*T, error) {
+	v := new(T)
+	if encoding == "" {
+		if err := json.Unmarshal([]byte(payload), v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	name, compressed := strings.CutSuffix(encoding, compressionGzip)
+	codecsMu.RLock()
+	codec, ok := codecs[name]
+	codecsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("queue: unknown codec %q", name)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("queue: invalid %s payload: %w", encoding, err)
+	}
+	if compressed {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("queue: invalid %s payload: %w", encoding, err)
+		}
+		if b, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("queue: invalid %s payload: %w", encoding, err)
+		}
+	}
+
+	if err = codec.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
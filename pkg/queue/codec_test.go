@@ -0,0 +1,173 @@
+package queue
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	payload := &TestPayload{ID: 1, Name: strings.Repeat("user ", 100), Email: "test@example.com"}
+
+	tests := []struct {
+		name      string
+		codec     Codec
+		threshold int
+		encoding  string
+	}{
+		{"json", JSONCodec, 0, ""},
+		{"json gzip", JSONCodec, 100, "json+gzip"},
+		{"json below threshold", JSONCodec, 10000, ""},
+		{"msgpack", MsgpackCodec, 0, "msgpack"},
+		{"msgpack gzip", MsgpackCodec, 100, "msgpack+gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, encoding, err := encodePayload(tt.codec, tt.threshold, payload)
+			if err != nil {
+				t.Fatalf("encodePayload returned error: %v", err)
+			}
+			if encoding != tt.encoding {
+				t.Errorf("Expected encoding %q, got %q", tt.encoding, encoding)
+			}
+
+			decoded, err := decodePayload[TestPayload](encoded, encoding)
+			if err != nil {
+				t.Fatalf("decodePayload returned error: %v", err)
+			}
+			if *decoded != *payload {
+				t.Errorf("Expected %+v, got %+v", payload, decoded)
+			}
+		})
+	}
+}
+
+func TestCodec_Compression(t *testing.T) {
+	payload := &TestPayload{Name: strings.Repeat("a", 10000)}
+
+	plain, _, _ := encodePayload(JSONCodec, 0, payload)
+	compressed, _, _ := encodePayload(JSONCodec, 1024, payload)
+	if len(compressed) >= len(plain)/10 {
+		t.Errorf("Expected the payload to be compressed, %d bytes for %d", len(compressed), len(plain))
+	}
+}
+
+func TestCodec_Protobuf(t *testing.T) {
+	encoded, encoding, err := encodePayload(ProtobufCodec, 0, wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("encodePayload returned error: %v", err)
+	}
+
+	decoded, err := decodePayload[wrapperspb.StringValue](encoded, encoding)
+	if err != nil {
+		t.Fatalf("decodePayload returned error: %v", err)
+	}
+	if decoded.GetValue() != "hello" {
+		t.Errorf("Expected hello, got %q", decoded.GetValue())
+	}
+
+	if _, _, err = encodePayload(ProtobufCodec, 0, &TestPayload{}); err == nil {
+		t.Error("Expected an error for a payload that is not a proto.Message")
+	}
+}
+
+func TestCodec_UnknownEncoding(t *testing.T) {
+	if _, err := decodePayload[TestPayload]("e30=", "unknown"); err == nil {
+		t.Error("Expected an error for an unknown codec")
+	}
+	if _, err := decodePayload[TestPayload]("not base64!", "msgpack"); err == nil {
+		t.Error("Expected an error for an invalid payload")
+	}
+}
+
+// reversedCodec is a custom codec, JSON with the payload reversed
+type reversedCodec struct{}
+
+func (reversedCodec) Name() string { return "reversed-json" }
+
+func (reversedCodec) Marshal(v any) ([]byte, error) {
+	b, err := JSONCodec.Marshal(v)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b, err
+}
+
+func (reversedCodec) Unmarshal(data []byte, v any) error {
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+	return JSONCodec.Unmarshal(data, v)
+}
+
+func TestRegisterCodec(t *testing.T) {
+	encoded, encoding, err := encodePayload(reversedCodec{}, 0, &TestPayload{ID: 7})
+	if err != nil {
+		t.Fatalf("encodePayload returned error: %v", err)
+	}
+
+	RegisterCodec(reversedCodec{})
+	decoded, err := decodePayload[TestPayload](encoded, encoding)
+	if err != nil {
+		t.Fatalf("decodePayload returned error: %v", err)
+	}
+	if decoded.ID != 7 {
+		t.Errorf("Expected ID 7, got %d", decoded.ID)
+	}
+}
+
+func TestQueue_DispatchMarshalError(t *testing.T) {
+	type invalid struct{ C chan int }
+
+	q := NewQueue[invalid]("invalid")
+	q.WithData(&invalid{C: make(chan int)})
+	if err := q.Dispatch(); err == nil {
+		t.Error("Expected Dispatch to return the marshal error")
+	}
+	if _, err := q.DispatchIn(0); err == nil {
+		t.Error("Expected DispatchIn to return the marshal error")
+	}
+}
+
+func TestProvider_Codec(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		// A worker decodes the jobs of every codec
+		for i, ops := range [][]func(*QueueOptions){
+			nil,
+			{WithCodec(MsgpackCodec)},
+			{WithCodec(MsgpackCodec), WithCompression(10)},
+		} {
+			q := NewQueue[TestPayload](queueName, ops...)
+			q.WithData(&TestPayload{ID: i, Name: "user"})
+			if err := q.Dispatch(); err != nil {
+				t.Fatalf("Dispatch returned error: %v", err)
+			}
+		}
+
+		received := make(chan TestPayload, 3)
+		runWorker(t, NewWorker[TestPayload](queueName), func(ctx context.Context, data *TestPayload) error {
+			received <- *data
+			return nil
+		})
+
+		seen := map[int]bool{}
+		for range 3 {
+			var data TestPayload
+			select {
+			case data = <-received:
+			case <-time.After(15 * time.Second):
+				t.Fatal("timeout")
+			}
+			if data.Name != "user" {
+				t.Errorf("Expected the decoded payload, got %+v", data)
+			}
+			seen[data.ID] = true
+		}
+		if len(seen) != 3 {
+			t.Errorf("Expected the 3 jobs, got %v", seen)
+		}
+	})
+}
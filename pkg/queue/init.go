@@ -2,11 +2,16 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/gocraft/work"
 	"github.com/gomodule/redigo/redis"
 )
@@ -22,6 +27,10 @@ var (
 	// namespace is the Redis namespace prefix for all queues.
 	// Set via APP_NAME environment variable. Used to isolate queues from different applications.
 	namespace = os.Getenv("APP_NAME")
+
+	// namespacePerQueue gives each gocraft/work queue its own namespace (QUEUE_NAMESPACE_PER_QUEUE=true),
+	// see workNamespace and MigrateNamespacePerQueue.
+	namespacePerQueue = os.Getenv("QUEUE_NAMESPACE_PER_QUEUE") == "true"
 	
 	// poolProvider determines which queue provider to use.
 	// Checks POOL_PROVIDER environment variable, defaults to "work" (gocraft/work).
@@ -39,8 +48,8 @@ var (
 	//   - Thread-safe initialization with sync.Once
 	redisPool *redis.Pool
 	
-	// taskInstance is the singleton task enqueuer instance.
-	taskInstance *Task
+	// taskInstances are the task enqueuers by provider.
+	taskInstances = map[string]*Task{}
 	
 	// maxConcurrent is the default maximum number of concurrent workers.
 	maxConcurrent uint = 10
@@ -48,16 +57,23 @@ var (
 	// queueOnce ensures Redis pool is created only once (thread-safe).
 	queueOnce sync.Once
 	
-	// taskOnce ensures task enqueuer is created only once (thread-safe).
-	taskOnce sync.Once
+	// taskMu ensures each task enqueuer is created only once (thread-safe).
+	taskMu sync.Mutex
 )
 
+// ErrNotSupported is returned when the queue provider doesn't support an operation.
+var ErrNotSupported = errors.New("queue: not supported by the provider")
+
 // Dispatcher defines the interface for dispatching jobs to the queue.
 // This interface allows different queue implementations while maintaining the same API.
 type Dispatcher[T any] interface {
 	Dispatch() error              // Dispatch a job (may create duplicates)
 	WithData(data *T)            // Set the job payload
 	DispatchUnique() error       // Dispatch a unique job (prevents duplicates)
+	DispatchIn(d time.Duration) (string, error)       // Dispatch a job after a delay, returns the job ID
+	DispatchAt(t time.Time) (string, error)           // Dispatch a job at a time, returns the job ID
+	DispatchUniqueIn(d time.Duration) (string, error) // Dispatch a unique job after a delay
+	DispatchUniqueAt(t time.Time) (string, error)     // Dispatch a unique job at a time
 }
 
 // Listen defines the interface for consuming jobs from the queue.
@@ -67,9 +83,109 @@ type Listen[T any] interface {
 	Stop()                                                       // Stop processing jobs
 }
 
-// Task wraps the gocraft/work enqueuer for dispatching jobs.
+// Task wraps the enqueuer of the provider for dispatching jobs.
+// The publisher is only set for the Watermill provider.
 type Task struct {
-	enqueue *work.Enqueuer
+	mu        sync.Mutex
+	enqueuers map[string]*work.Enqueuer // gocraft/work enqueuers by namespace
+	publisher message.Publisher         // Watermill Redis Streams publisher
+}
+
+// workNamespace returns the gocraft/work namespace of a queue: APP_NAME,
+// or APP_NAME:<queue> with QUEUE_NAMESPACE_PER_QUEUE=true.
+//
+// WHY a namespace per queue?
+//   - gocraft/work moves the scheduled and retried jobs back to their queue from every worker pool of the namespace
+//   - A pool moves the jobs of a queue it doesn't process to the dead set ("unknown job when requeueing")
+//   - One pool per namespace keeps each queue's scheduled, retry and dead sets to its own worker
+//
+// In the shared namespace (default), the workers of a process share one pool that knows all their queues,
+// see workPool. Each process running workers must run the workers of every queue that has scheduled
+// (DispatchIn/DispatchAt) or retried jobs.
+//
+// NOTE: the namespace is the Redis key layout, switching loses the waiting jobs, see MigrateNamespacePerQueue.
+func workNamespace(queueName string) string {
+	if namespacePerQueue {
+		return namespace + ":" + queueName
+	}
+	return namespace
+}
+
+// workKeyPrefix returns the prefix of the gocraft/work keys of a namespace, "<namespace>:" unless empty
+func workKeyPrefix(ns string) string {
+	if ns != "" && !strings.HasSuffix(ns, ":") {
+		return ns + ":"
+	}
+	return ns
+}
+
+// enqueuer returns the gocraft/work enqueuer of the queue
+func (t *Task) enqueuer(queueName string) *work.Enqueuer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ns := workNamespace(queueName)
+	enqueuer, ok := t.enqueuers[ns]
+	if !ok {
+		enqueuer = work.NewEnqueuer(ns, instancePool())
+		t.enqueuers[ns] = enqueuer
+	}
+	return enqueuer
+}
+
+// jobArgs returns the gocraft/work args of a job, the encoding is omitted for plain JSON
+func jobArgs(payload, encoding string) work.Q {
+	args := work.Q{"payload": payload}
+	if encoding != "" {
+		args["encoding"] = encoding
+	}
+	return args
+}
+
+// dispatch adds a job with the serialized payload to the queue.
+// A unique job is not added if a job with the same payload is waiting.
+func (t *Task) dispatch(queueName, payload, encoding string, unique bool) error {
+	if t.publisher != nil {
+		return t.publishWatermill(queueName, payload, encoding, unique)
+	}
+
+	var err error
+	if unique {
+		_, err = t.enqueuer(queueName).EnqueueUnique(queueName, jobArgs(payload, encoding))
+	} else {
+		_, err = t.enqueuer(queueName).Enqueue(queueName, jobArgs(payload, encoding))
+	}
+	return err
+}
+
+// schedule adds a job with the serialized payload to the queue, to run at the given time.
+// It returns the job ID, empty if a unique job with the same payload is waiting.
+//
+// NOTE: not supported by Watermill, Redis Streams can't delay a message.
+func (t *Task) schedule(queueName, payload, encoding string, at time.Time, unique bool) (string, error) {
+	if t.publisher != nil {
+		return "", ErrNotSupported
+	}
+
+	// gocraft/work schedules in seconds, round up so the job never runs early
+	seconds := int64(math.Ceil(time.Until(at).Seconds()))
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	var (
+		job *work.ScheduledJob
+		err error
+	)
+	if unique {
+		job, err = t.enqueuer(queueName).EnqueueUniqueIn(queueName, seconds, jobArgs(payload, encoding))
+	} else {
+		job, err = t.enqueuer(queueName).EnqueueIn(queueName, seconds, jobArgs(payload, encoding))
+	}
+	if err != nil || job == nil {
+		return "", err
+	}
+	return job.ID, nil
 }
 
 // instancePool returns the singleton Redis connection pool.
@@ -122,18 +238,27 @@ func Ping() error {
 	return err
 }
 
-// initQueue initializes and returns the singleton task enqueuer.
+// initQueue initializes and returns the singleton task enqueuer of the provider.
 // Creates the enqueuer on first call (thread-safe).
 //
 // WHY singleton?
 //   - Enqueuer is lightweight but should be shared
-//   - Ensures consistent namespace across all queue operations
+//   - Shares the enqueuers of the queues
 //   - Thread-safe initialization
 func initQueue() *Task {
-	taskOnce.Do(func() {
-		taskInstance = &Task{
-			enqueue: work.NewEnqueuer(namespace, instancePool()),
-		}
-	})
-	return taskInstance
+	provider := poolProvider()
+
+	taskMu.Lock()
+	defer taskMu.Unlock()
+
+	if task, ok := taskInstances[provider]; ok {
+		return task
+	}
+
+	task := &Task{enqueuers: map[string]*work.Enqueuer{}}
+	if provider == poolWaterMill {
+		task.publisher = newWatermillPublisher()
+	}
+	taskInstances[provider] = task
+	return task
 }
\ No newline at end of file
@@ -0,0 +1,39 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// JobInfo is the metadata of the job being processed.
+type JobInfo struct {
+	ID         string    // Job ID (gocraft/work job ID or Watermill message UUID)
+	Queue      string    // Name of the queue
+	Attempt    int64     // Attempt number, 1 for the first run
+	EnqueuedAt time.Time // Time the job was dispatched
+}
+
+// jobKey is the context key of the JobInfo
+type jobKey struct{}
+
+// withJob returns a copy of the context with the job metadata
+func withJob(ctx context.Context, job *JobInfo) context.Context {
+	return context.WithValue(ctx, jobKey{}, job)
+}
+
+// JobFromContext returns the metadata of the job, in the context of a Worker handler.
+//
+// Example:
+//
+//	worker.RunWithContext(func(ctx context.Context, data *MyPayload) error {
+//	    job, _ := queue.JobFromContext(ctx)
+//	    slog.InfoContext(ctx, "processing", "job", job.ID, "attempt", job.Attempt)
+//	    if job.Attempt > 3 {
+//	        return sendWithFallback(data)
+//	    }
+//	    return send(data)
+//	})
+func JobFromContext(ctx context.Context) (*JobInfo, bool) {
+	job, ok := ctx.Value(jobKey{}).(*JobInfo)
+	return job, ok
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"github.com/gomodule/redigo/redis"
+)
+
+// workMoveSetJobs moves the jobs of a queue from a sorted set (scheduled, retry or dead) to another, with their time.
+// KEYS[1] = source set, KEYS[2] = destination set, ARGV[1] = job name
+var workMoveSetJobs = redis.NewScript(2, `
+local moved = 0
+local jobs = redis.call('zrange', KEYS[1], 0, -1, 'WITHSCORES')
+for i = 1, #jobs, 2 do
+  if cjson.decode(jobs[i])['name'] == ARGV[1] then
+    redis.call('zadd', KEYS[2], jobs[i+1], jobs[i])
+    redis.call('zrem', KEYS[1], jobs[i])
+    moved = moved + 1
+  end
+end
+return moved
+`)
+
+// MigrateNamespacePerQueue moves the gocraft/work jobs of the queues from the shared namespace (APP_NAME)
+// to the namespace of each queue (APP_NAME:<queue>), it returns the number of moved jobs.
+// The waiting, scheduled, retry and dead jobs are moved, with the unique keys.
+//
+// HOW to switch to QUEUE_NAMESPACE_PER_QUEUE=true:
+//  1. Deploy every producer and worker of the queues with QUEUE_NAMESPACE_PER_QUEUE=true,
+//     the workers stopped gracefully have no job in progress
+//  2. Once no replica runs without it, call MigrateNamespacePerQueue with every queue of the app
+//  3. It can run again, to move the jobs enqueued by a late replica
+//
+// NOTE: the sets of the shared namespace are read in full by a script, run it off-peak for large dead sets.
+//
+// Example:
+//
+//	moved, err := queue.MigrateNamespacePerQueue("email", "order-sync")
+func MigrateNamespacePerQueue(queueNames ...string) (int, error) {
+	conn := instancePool().Get()
+	defer conn.Close()
+
+	from := workKeyPrefix(namespace)
+	moved := 0
+	for _, queueName := range queueNames {
+		to := workKeyPrefix(namespace + ":" + queueName)
+		if _, err := conn.Do("SADD", to+"known_jobs", queueName); err != nil {
+			return moved, err
+		}
+
+		// Oldest first, the order of the queue is kept
+		for {
+			job, err := conn.Do("RPOPLPUSH", from+"jobs:"+queueName, to+"jobs:"+queueName)
+			if err != nil {
+				return moved, err
+			}
+			if job == nil {
+				break
+			}
+			moved++
+		}
+
+		for _, set := range []string{"scheduled", "retry", "dead"} {
+			n, err := redis.Int(workMoveSetJobs.Do(conn, from+set, to+set, queueName))
+			if err != nil {
+				return moved, err
+			}
+			moved += n
+		}
+
+		if err := migrateUniqueKeys(conn, from+"unique:"+queueName+":", to+"unique:"+queueName+":"); err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
+// migrateUniqueKeys renames the unique keys of a queue, their expiry is kept
+func migrateUniqueKeys(conn redis.Conn, from, to string) error {
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", from+"*", "COUNT", 1000))
+		if err != nil {
+			return err
+		}
+		keys, _ := redis.Strings(values[1], nil)
+		for _, key := range keys {
+			// A key that expired since the scan has nothing to move
+			_, err = conn.Do("RENAME", key, to+key[len(from):])
+			if err != nil && err.Error() != "ERR no such key" {
+				return err
+			}
+		}
+
+		if cursor, _ = redis.Int(values[0], nil); cursor == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// appNamespace sets APP_NAME until the end of the test
+func appNamespace(t *testing.T, ns string) {
+	previous := namespace
+	namespace = ns
+	t.Cleanup(func() { namespace = previous })
+}
+
+func TestWorkNamespace_Shared(t *testing.T) {
+	appNamespace(t, "app")
+	queueName := adminQueue(t)
+
+	// The key layout of the jobs before QUEUE_NAMESPACE_PER_QUEUE, for the existing workers
+	q := NewQueue[TestPayload](queueName)
+	q.WithData(&TestPayload{ID: 1})
+	if err := q.Dispatch(); err != nil {
+		t.Fatalf("Dispatch returned error: %v", err)
+	}
+	if _, err := q.DispatchBatch([]*TestPayload{{ID: 2}}); err != nil {
+		t.Fatalf("DispatchBatch returned error: %v", err)
+	}
+
+	conn := instancePool().Get()
+	defer conn.Close()
+	if n, _ := redis.Int(conn.Do("LLEN", "app:jobs:"+queueName)); n != 2 {
+		t.Errorf("Expected 2 jobs in app:jobs:%s, got %d", queueName, n)
+	}
+}
+
+func TestAdmin_SharedNamespace(t *testing.T) {
+	queueName := adminQueue(t)
+	other := queueName + "-other"
+	addDeadJob(t, queueName, "mine", time.Now(), `{"id":1}`)
+	addDeadJob(t, other, "other", time.Now(), `{"id":2}`)
+
+	admin := NewAdmin[TestPayload](queueName)
+	dead, total, err := admin.DeadJobs(1)
+	if err != nil {
+		t.Fatalf("DeadJobs returned error: %v", err)
+	}
+	if total != 1 || len(dead) != 1 || dead[0].ID != "mine" {
+		t.Errorf("Expected only the job of the queue, got %d %+v", total, dead)
+	}
+
+	if err = admin.DeleteAllDead(); err != nil {
+		t.Fatalf("DeleteAllDead returned error: %v", err)
+	}
+	if _, total, _ = NewAdmin[TestPayload](other).DeadJobs(1); total != 1 {
+		t.Errorf("Expected the job of the other queue to be kept, got %d", total)
+	}
+}
+
+func TestMigrateNamespacePerQueue(t *testing.T) {
+	appNamespace(t, "app")
+	queueName := adminQueue(t)
+
+	q := NewQueue[TestPayload](queueName)
+	for i := 1; i <= 2; i++ {
+		q.WithData(&TestPayload{ID: i})
+		if err := q.Dispatch(); err != nil {
+			t.Fatalf("Dispatch returned error: %v", err)
+		}
+	}
+	q.WithData(&TestPayload{ID: 3})
+	if err := q.DispatchUnique(); err != nil {
+		t.Fatalf("DispatchUnique returned error: %v", err)
+	}
+	if _, err := q.DispatchIn(time.Hour); err != nil {
+		t.Fatalf("DispatchIn returned error: %v", err)
+	}
+	addDeadJob(t, queueName, "dead", time.Now(), `{"id":4}`)
+
+	moved, err := MigrateNamespacePerQueue(queueName)
+	if err != nil {
+		t.Fatalf("MigrateNamespacePerQueue returned error: %v", err)
+	}
+	if moved != 5 {
+		t.Errorf("Expected 5 moved jobs, got %d", moved)
+	}
+
+	perQueueNamespace(t)
+	admin := NewAdmin[TestPayload](queueName)
+	if _, total, _ := admin.ScheduledJobs(1); total != 1 {
+		t.Errorf("Expected the scheduled job, got %d", total)
+	}
+	if _, total, _ := admin.DeadJobs(1); total != 1 {
+		t.Errorf("Expected the dead job, got %d", total)
+	}
+
+	// The unique key moved with its job
+	q.WithData(&TestPayload{ID: 3})
+	if err = q.DispatchUnique(); err != nil {
+		t.Fatalf("DispatchUnique returned error: %v", err)
+	}
+
+	received := make(chan int, 4)
+	runWorker(t, NewWorker[TestPayload](queueName), func(ctx context.Context, data *TestPayload) error {
+		received <- data.ID
+		return nil
+	})
+	seen := map[int]bool{}
+	for range 3 {
+		select {
+		case id := <-received:
+			seen[id] = true
+		case <-time.After(15 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	if !seen[1] || !seen[2] || !seen[3] {
+		t.Errorf("Expected the jobs 1, 2 and 3, got %v", seen)
+	}
+	select {
+	case id := <-received:
+		t.Errorf("Unexpected job %d", id)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -0,0 +1,188 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/robfig/cron"
+)
+
+var (
+	// periodicJobs are the registered periodic jobs by name.
+	periodicJobs = map[string]*periodicJob{}
+	// periodicMu protects periodicJobs.
+	periodicMu sync.Mutex
+)
+
+// PeriodicJob describes a registered periodic job.
+type PeriodicJob struct {
+	Name     string         // Name of the job, also the queue it is dispatched to
+	Spec     string         // Cron spec, without the timezone
+	Location *time.Location // Timezone of the spec
+	Next     time.Time      // Next run time, in Location
+}
+
+// periodicJob is a registered periodic job with its dispatch function
+type periodicJob struct {
+	name     string
+	spec     string
+	location *time.Location
+	schedule cron.Schedule
+	dispatch func(at time.Time) error
+}
+
+// next returns the next tick after t, in the timezone of the job
+func (p *periodicJob) next(t time.Time) time.Time {
+	return p.schedule.Next(t.In(p.location))
+}
+
+// RegisterPeriodic registers a job dispatched to the queue name at each tick of the cron spec,
+// with the payload built by payloadFn for the tick. The jobs are dispatched by RunPeriodic.
+//
+// The spec is a standard cron spec (minute hour day month weekday) or a descriptor
+// (@hourly, @daily, @every 1h30m), in UTC unless it starts with CRON_TZ=<timezone>.
+//
+// WHY a lock per tick?
+//   - Every replica runs RunPeriodic
+//   - The first replica to lock the tick in Redis dispatches the job, the others skip it
+//
+// Example:
+//
+//	queue.RegisterPeriodic("daily-report", "CRON_TZ=Asia/Ho_Chi_Minh 0 9 * * *",
+//	    func(at time.Time) (*Report, error) {
+//	        return &Report{Day: at.Format(time.DateOnly)}, nil
+//	    })
+//	go queue.RunPeriodic(ctx)
+//
+//	worker := queue.NewWorker[Report]("daily-report")
+func RegisterPeriodic[T any](name, spec string, payloadFn func(at time.Time) (*T, error)) error {
+	location := time.UTC
+	if tz, rest, ok := strings.Cut(spec, " "); ok && strings.HasPrefix(tz, "CRON_TZ=") {
+		var err error
+		if location, err = time.LoadLocation(strings.TrimPrefix(tz, "CRON_TZ=")); err != nil {
+			return fmt.Errorf("queue: invalid timezone of periodic job %s: %w", name, err)
+		}
+		spec = strings.TrimSpace(rest)
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("queue: invalid spec of periodic job %s: %w", name, err)
+	}
+
+	periodicMu.Lock()
+	defer periodicMu.Unlock()
+
+	if _, ok := periodicJobs[name]; ok {
+		return fmt.Errorf("queue: periodic job %s is already registered", name)
+	}
+
+	periodicJobs[name] = &periodicJob{
+		name:     name,
+		spec:     spec,
+		location: location,
+		schedule: schedule,
+		dispatch: func(at time.Time) error {
+			payload, err := payloadFn(at)
+			if err != nil {
+				return err
+			}
+			q := NewQueue[T](name)
+			q.WithData(payload)
+			return q.Dispatch()
+		},
+	}
+	return nil
+}
+
+// PeriodicJobs returns the registered periodic jobs with their next run time, sorted by name.
+func PeriodicJobs() []PeriodicJob {
+	periodicMu.Lock()
+	defer periodicMu.Unlock()
+
+	now := time.Now()
+	jobs := make([]PeriodicJob, 0, len(periodicJobs))
+	for _, p := range periodicJobs {
+		jobs = append(jobs, PeriodicJob{
+			Name:     p.name,
+			Spec:     p.spec,
+			Location: p.location,
+			Next:     p.next(now),
+		})
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// RunPeriodic dispatches the registered periodic jobs until the context is cancelled.
+// Register the jobs before, the jobs registered later are not run.
+func RunPeriodic(ctx context.Context) {
+	periodicMu.Lock()
+	jobs := make([]*periodicJob, 0, len(periodicJobs))
+	for _, p := range periodicJobs {
+		jobs = append(jobs, p)
+	}
+	periodicMu.Unlock()
+
+	wg := sync.WaitGroup{}
+	for _, p := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// run dispatches the job at each tick until the context is cancelled
+func (p *periodicJob) run(ctx context.Context) {
+	for {
+		tick := p.next(time.Now())
+		timer := time.NewTimer(time.Until(tick))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		locked, err := p.lock(tick)
+		if err != nil {
+			slog.Error("queue: failed to lock periodic job", "job", p.name, "tick", tick, "err", err)
+			continue
+		}
+		if !locked {
+			continue // Dispatched by another replica
+		}
+
+		if err = p.dispatch(tick); err != nil {
+			slog.Error("queue: failed to dispatch periodic job", "job", p.name, "tick", tick, "err", err)
+		}
+	}
+}
+
+// lock locks the tick of the job in Redis, it returns false if the tick is already locked.
+// The lock expires a minute after the following tick, so replicas with a clock skew still see it.
+func (p *periodicJob) lock(tick time.Time) (bool, error) {
+	conn := instancePool().Get()
+	defer conn.Close()
+
+	key := namespace + ":periodic:" + p.name + ":" + strconv.FormatInt(tick.Unix(), 10)
+	ttl := p.next(tick).Sub(tick) + time.Minute
+
+	_, err := redis.String(conn.Do("SET", key, "1", "NX", "PX", ttl.Milliseconds()))
+	if errors.Is(err, redis.ErrNil) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocraft/work"
+)
+
+// resetPeriodic removes the periodic jobs registered by the test
+func resetPeriodic(t *testing.T) {
+	t.Cleanup(func() {
+		periodicMu.Lock()
+		periodicJobs = map[string]*periodicJob{}
+		periodicMu.Unlock()
+	})
+}
+
+func TestRegisterPeriodic_InvalidSpec(t *testing.T) {
+	resetPeriodic(t)
+	payload := func(at time.Time) (*TestPayload, error) { return &TestPayload{}, nil }
+
+	if err := RegisterPeriodic("invalid", "not a spec", payload); err == nil {
+		t.Error("Expected an error for an invalid spec")
+	}
+	if err := RegisterPeriodic("invalid-tz", "CRON_TZ=Mars/Olympus 0 9 * * *", payload); err == nil {
+		t.Error("Expected an error for an invalid timezone")
+	}
+	if err := RegisterPeriodic("report", "@daily", payload); err != nil {
+		t.Fatalf("RegisterPeriodic returned error: %v", err)
+	}
+	if err := RegisterPeriodic("report", "@hourly", payload); err == nil {
+		t.Error("Expected an error for a duplicate name")
+	}
+}
+
+func TestPeriodicJobs(t *testing.T) {
+	resetPeriodic(t)
+	payload := func(at time.Time) (*TestPayload, error) { return &TestPayload{}, nil }
+
+	if err := RegisterPeriodic("morning", "CRON_TZ=Asia/Ho_Chi_Minh 0 9 * * *", payload); err != nil {
+		t.Fatalf("RegisterPeriodic returned error: %v", err)
+	}
+	if err := RegisterPeriodic("hourly", "@hourly", payload); err != nil {
+		t.Fatalf("RegisterPeriodic returned error: %v", err)
+	}
+
+	jobs := PeriodicJobs()
+	if len(jobs) != 2 || jobs[0].Name != "hourly" || jobs[1].Name != "morning" {
+		t.Fatalf("Expected the jobs sorted by name, got %+v", jobs)
+	}
+
+	morning := jobs[1]
+	if morning.Spec != "0 9 * * *" || morning.Location.String() != "Asia/Ho_Chi_Minh" {
+		t.Errorf("Expected the spec and timezone to be parsed, got %q %v", morning.Spec, morning.Location)
+	}
+	if morning.Next.Hour() != 9 || morning.Next.Minute() != 0 || !morning.Next.After(time.Now()) {
+		t.Errorf("Expected the next run at 9:00 in its timezone, got %v", morning.Next)
+	}
+	if jobs[0].Location != time.UTC {
+		t.Errorf("Expected UTC by default, got %v", jobs[0].Location)
+	}
+}
+
+func TestRunPeriodic_OncePerTick(t *testing.T) {
+	resetPeriodic(t)
+	name := t.Name() + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	var mu sync.Mutex
+	ticks := map[time.Time]int{}
+	err := RegisterPeriodic(name, "@every 1s", func(at time.Time) (*TestPayload, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		ticks[at]++
+		return &TestPayload{ID: int(at.Unix())}, nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterPeriodic returned error: %v", err)
+	}
+
+	// Three replicas
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			RunPeriodic(ctx)
+		}()
+	}
+	wg.Wait()
+
+	if len(ticks) < 2 {
+		t.Fatalf("Expected at least 2 ticks, got %v", ticks)
+	}
+	for tick, n := range ticks {
+		if n != 1 {
+			t.Errorf("Expected tick %v to be dispatched once, got %d", tick, n)
+		}
+	}
+
+	queues, err := work.NewClient(workNamespace(name), instancePool()).Queues()
+	if err != nil {
+		t.Fatalf("Queues returned error: %v", err)
+	}
+	var count int64
+	for _, queue := range queues {
+		if queue.JobName == name {
+			count = queue.Count
+		}
+	}
+	if count != int64(len(ticks)) {
+		t.Errorf("Expected %d jobs in the queue, got %d", len(ticks), count)
+	}
+}
@@ -0,0 +1,365 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gocraft/work"
+)
+
+func TestMain(m *testing.M) {
+	server, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("REDIS_HOST", server.Host())
+	os.Setenv("REDIS_PORT", server.Port())
+	watermillRetryInterval = 10 * time.Millisecond
+	workBackoff = func(job *work.Job) int64 { return 0 }
+
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
+}
+
+// providers runs the test against each queue provider
+func providers(t *testing.T, test func(t *testing.T, queueName string)) {
+	for _, provider := range []string{poolWork, poolWaterMill} {
+		t.Run(provider, func(t *testing.T) {
+			previous := poolProvider
+			poolProvider = func() string { return provider }
+			t.Cleanup(func() { poolProvider = previous })
+
+			// Unique per run, the Redis server is shared by -count runs
+			test(t, t.Name()+strconv.FormatInt(time.Now().UnixNano(), 10))
+		})
+	}
+}
+
+// perQueueNamespace gives each queue its own gocraft/work namespace until the end of the test
+func perQueueNamespace(t *testing.T) {
+	previous := namespacePerQueue
+	namespacePerQueue = true
+	t.Cleanup(func() { namespacePerQueue = previous })
+}
+
+// runWorker starts the worker and stops it at the end of the test
+func runWorker[T any](t *testing.T, worker *Worker[T], f func(ctx context.Context, data *T) error) {
+	t.Helper()
+	go worker.RunWithContext(f)
+	t.Cleanup(worker.Stop)
+}
+
+// waitFor waits until the condition is true
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProvider_Dispatch(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		for i := 1; i <= 3; i++ {
+			q := NewQueue[TestPayload](queueName)
+			q.WithData(&TestPayload{ID: i, Name: "user"})
+			if err := q.Dispatch(); err != nil {
+				t.Fatalf("Dispatch returned error: %v", err)
+			}
+		}
+
+		var mu sync.Mutex
+		received := map[int]string{}
+		runWorker(t, NewWorker[TestPayload](queueName), func(ctx context.Context, data *TestPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			received[data.ID] = data.Name
+			return nil
+		})
+
+		waitFor(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(received) == 3
+		})
+		for i := 1; i <= 3; i++ {
+			if received[i] != "user" {
+				t.Errorf("Expected job %d to be received with its payload, got %v", i, received)
+			}
+		}
+	})
+}
+
+func TestProvider_DispatchUnique(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		for i := 0; i < 3; i++ {
+			q := NewQueue[TestPayload](queueName)
+			q.WithData(&TestPayload{ID: 1})
+			if err := q.DispatchUnique(); err != nil {
+				t.Fatalf("DispatchUnique returned error: %v", err)
+			}
+		}
+		other := NewQueue[TestPayload](queueName)
+		other.WithData(&TestPayload{ID: 2})
+		if err := other.DispatchUnique(); err != nil {
+			t.Fatalf("DispatchUnique returned error: %v", err)
+		}
+
+		var calls atomic.Int32
+		runWorker(t, NewWorker[TestPayload](queueName), func(ctx context.Context, data *TestPayload) error {
+			calls.Add(1)
+			return nil
+		})
+
+		waitFor(t, func() bool { return calls.Load() >= 2 })
+		time.Sleep(200 * time.Millisecond)
+		if n := calls.Load(); n != 2 {
+			t.Errorf("Expected 2 jobs, got %d", n)
+		}
+	})
+}
+
+func TestWatermill_UniqueTTL(t *testing.T) {
+	task := &Task{publisher: newWatermillPublisher()}
+	queueName := adminQueue(t)
+	if err := task.publishWatermill(queueName, `{"id":1}`, "", true); err != nil {
+		t.Fatalf("publishWatermill returned error: %v", err)
+	}
+
+	// A job lost before a worker takes it must not lock its payload forever
+	ttl := instanceClient().TTL(context.Background(), watermillUniqueKey(queueName, `{"id":1}`)).Val()
+	if ttl <= 0 || ttl > watermillUniqueTTL {
+		t.Errorf("Expected the unique lock to expire, got TTL %v", ttl)
+	}
+}
+
+func TestProvider_Retry(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		q := NewQueue[TestPayload](queueName)
+		q.WithData(&TestPayload{ID: 1})
+		if err := q.Dispatch(); err != nil {
+			t.Fatalf("Dispatch returned error: %v", err)
+		}
+
+		var calls atomic.Int32
+		runWorker(t, NewWorker[TestPayload](queueName, WithMaxFails(2)), func(ctx context.Context, data *TestPayload) error {
+			if calls.Add(1) == 1 {
+				return errors.New("temporary failure")
+			}
+			return nil
+		})
+
+		waitFor(t, func() bool { return calls.Load() == 2 })
+	})
+}
+
+func TestProvider_MaxTimeout(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		q := NewQueue[TestPayload](queueName)
+		q.WithData(&TestPayload{ID: 1})
+		if err := q.Dispatch(); err != nil {
+			t.Fatalf("Dispatch returned error: %v", err)
+		}
+
+		deadlines := make(chan bool, 1)
+		runWorker(t, NewWorker[TestPayload](queueName, WithMaxTimeout(30)), func(ctx context.Context, data *TestPayload) error {
+			_, ok := ctx.Deadline()
+			deadlines <- ok
+			return nil
+		})
+
+		select {
+		case ok := <-deadlines:
+			if !ok {
+				t.Error("Expected the job context to have a deadline")
+			}
+		case <-time.After(15 * time.Second):
+			t.Fatal("timeout")
+		}
+	})
+}
+
+func TestProvider_Stop(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		worker := NewWorker[TestPayload](queueName)
+		go worker.RunWithContext(func(ctx context.Context, data *TestPayload) error { return nil })
+		time.Sleep(100 * time.Millisecond)
+
+		done := make(chan struct{})
+		go func() {
+			worker.Stop()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(15 * time.Second):
+			t.Fatal("Stop did not return")
+		}
+	})
+}
+
+func TestProvider_DispatchIn(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		q := NewQueue[TestPayload](queueName)
+		q.WithData(&TestPayload{ID: 1})
+		id, err := q.DispatchIn(time.Second)
+		if poolProvider() == poolWaterMill {
+			if !errors.Is(err, ErrNotSupported) {
+				t.Fatalf("Expected ErrNotSupported, got %v", err)
+			}
+			return
+		}
+		if err != nil || id == "" {
+			t.Fatalf("DispatchIn returned id %q, error %v", id, err)
+		}
+
+		jobs, _, err := work.NewClient(workNamespace(queueName), instancePool()).ScheduledJobs(1)
+		if err != nil {
+			t.Fatalf("ScheduledJobs returned error: %v", err)
+		}
+		// The namespace is shared with the other queues
+		scheduled := false
+		for _, job := range jobs {
+			scheduled = scheduled || job.ID == id
+		}
+		if !scheduled {
+			t.Fatalf("Expected scheduled job %s, got %v", id, jobs)
+		}
+
+		// The worker of another queue must not requeue the job, see workPool
+		runWorker(t, NewWorker[TestPayload](queueName+"-other"), func(ctx context.Context, data *TestPayload) error {
+			return nil
+		})
+
+		start := time.Now()
+		var ran atomic.Int64
+		runWorker(t, NewWorker[TestPayload](queueName), func(ctx context.Context, data *TestPayload) error {
+			ran.Store(int64(time.Since(start)))
+			return nil
+		})
+
+		waitFor(t, func() bool { return ran.Load() > 0 })
+		if elapsed := time.Duration(ran.Load()); elapsed < 500*time.Millisecond {
+			t.Errorf("Expected the job to wait for its delay, ran after %v", elapsed)
+		}
+	})
+}
+
+func TestProvider_DispatchUniqueAt(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		at := time.Now().Add(time.Hour)
+
+		q := NewQueue[TestPayload](queueName)
+		q.WithData(&TestPayload{ID: 1})
+		id, err := q.DispatchUniqueAt(at)
+		if poolProvider() == poolWaterMill {
+			if !errors.Is(err, ErrNotSupported) {
+				t.Fatalf("Expected ErrNotSupported, got %v", err)
+			}
+			return
+		}
+		if err != nil || id == "" {
+			t.Fatalf("DispatchUniqueAt returned id %q, error %v", id, err)
+		}
+
+		duplicate, err := q.DispatchUniqueAt(at)
+		if err != nil {
+			t.Fatalf("DispatchUniqueAt returned error: %v", err)
+		}
+		if duplicate != "" {
+			t.Errorf("Expected no job ID for a duplicate, got %q", duplicate)
+		}
+	})
+}
+
+func TestProvider_PayloadIsolation(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		for i := 1; i <= 20; i++ {
+			q := NewQueue[TestPayload](queueName)
+			q.WithData(&TestPayload{ID: i})
+			if err := q.Dispatch(); err != nil {
+				t.Fatalf("Dispatch returned error: %v", err)
+			}
+		}
+
+		var done, mixed atomic.Int32
+		runWorker(t, NewWorker[TestPayload](queueName, WithMaxConcurrency(5)), func(ctx context.Context, data *TestPayload) error {
+			id := data.ID
+			time.Sleep(20 * time.Millisecond) // Let the other jobs run
+			if data.ID != id {
+				mixed.Add(1)
+			}
+			done.Add(1)
+			return nil
+		})
+
+		waitFor(t, func() bool { return done.Load() == 20 })
+		if n := mixed.Load(); n != 0 {
+			t.Errorf("Expected each job to have its own payload, %d jobs saw another payload", n)
+		}
+	})
+}
+
+func TestProvider_JobFromContext(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		q := NewQueue[TestPayload](queueName)
+		q.WithData(&TestPayload{ID: 1})
+		if err := q.Dispatch(); err != nil {
+			t.Fatalf("Dispatch returned error: %v", err)
+		}
+
+		jobs := make(chan JobInfo, 2)
+		runWorker(t, NewWorker[TestPayload](queueName, WithMaxFails(2)), func(ctx context.Context, data *TestPayload) error {
+			job, ok := JobFromContext(ctx)
+			if !ok {
+				t.Error("Expected the job in the context")
+				return nil
+			}
+			jobs <- *job
+			if job.Attempt == 1 {
+				return errors.New("temporary failure")
+			}
+			return nil
+		})
+
+		var first, second JobInfo
+		for i, job := range []*JobInfo{&first, &second} {
+			select {
+			case *job = <-jobs:
+			case <-time.After(15 * time.Second):
+				t.Fatalf("timeout waiting for attempt %d", i+1)
+			}
+		}
+
+		if first.Attempt != 1 || second.Attempt != 2 {
+			t.Errorf("Expected attempts 1 and 2, got %d and %d", first.Attempt, second.Attempt)
+		}
+		if first.ID == "" || first.ID != second.ID {
+			t.Errorf("Expected the same job ID on retry, got %q and %q", first.ID, second.ID)
+		}
+		if first.Queue != queueName {
+			t.Errorf("Expected queue %s, got %s", queueName, first.Queue)
+		}
+		if time.Since(first.EnqueuedAt) > time.Minute {
+			t.Errorf("Expected the enqueue time, got %v", first.EnqueuedAt)
+		}
+	})
+}
+
+func TestJobFromContext_Missing(t *testing.T) {
+	if _, ok := JobFromContext(context.Background()); ok {
+		t.Error("Expected no job outside a handler")
+	}
+}
@@ -1,21 +1,67 @@
 package queue
 
 import (
-	"encoding/json"
-
-	"github.com/gocraft/work"
+	"time"
 )
 
 // Queue represents a job queue with a typed payload.
-// It provides methods to dispatch jobs to Redis-backed queues using gocraft/work.
+// It provides methods to dispatch jobs to Redis-backed queues using gocraft/work
+// or Watermill (POOL_PROVIDER=watermill).
 //
 // Generic type [T any] allows type-safe job payloads:
 //   type UserPayload struct { ID int; Name string }
 //   q := queue.NewQueue[UserPayload]("user-queue")
 type Queue[T any] struct {
-	task      *Task  // Task enqueuer for dispatching jobs
-	queueName string // Name of the queue (e.g., "user-created", "email-send")
-	payload   *T     // The job payload data (set via WithData)
+	task      *Task         // Task enqueuer for dispatching jobs
+	queueName string        // Name of the queue (e.g., "user-created", "email-send")
+	payload   *T            // The job payload data (set via WithData)
+	options   *QueueOptions // Payload encoding options
+}
+
+// QueueOptions configures how the payloads are encoded.
+type QueueOptions struct {
+	// Codec encodes the payloads.
+	// Default: JSONCodec
+	Codec Codec
+
+	// CompressAbove compresses the encoded payloads larger than this size (in bytes) with gzip.
+	// Default: 0 (no compression)
+	CompressAbove int
+
+	// BatchSize is the number of jobs DispatchBatch sends to Redis per round trip.
+	// Default: 500
+	BatchSize int
+}
+
+// WithCodec returns an option function to set the payload codec.
+// The workers decode the jobs of any registered codec, switching codec doesn't break the waiting jobs.
+//
+// Example:
+//   q := queue.NewQueue[MyPayload]("my-queue", queue.WithCodec(queue.MsgpackCodec))
+func WithCodec(codec Codec) func(*QueueOptions) {
+	return func(o *QueueOptions) {
+		o.Codec = codec
+	}
+}
+
+// WithCompression returns an option function to compress the payloads larger than size bytes.
+//
+// Example:
+//   q := queue.NewQueue[Catalog]("catalog-sync", queue.WithCompression(4096))
+func WithCompression(size int) func(*QueueOptions) {
+	return func(o *QueueOptions) {
+		o.CompressAbove = size
+	}
+}
+
+// WithBatchSize returns an option function to set the number of jobs DispatchBatch sends per round trip.
+//
+// Example:
+//   q := queue.NewQueue[Product]("product-sync", queue.WithBatchSize(1000))
+func WithBatchSize(size int) func(*QueueOptions) {
+	return func(o *QueueOptions) {
+		o.BatchSize = size
+	}
 }
 
 // NewQueue creates a new Queue instance for the given queue name.
@@ -24,10 +70,16 @@ type Queue[T any] struct {
 //   q := queue.NewQueue[MyPayload]("my-queue")
 //   q.WithData(&MyPayload{ID: 1})
 //   q.Dispatch()
-func NewQueue[T any](queueName string) *Queue[T] {
+func NewQueue[T any](queueName string, ops ...func(*QueueOptions)) *Queue[T] {
+	options := &QueueOptions{Codec: JSONCodec}
+	for _, op := range ops {
+		op(options)
+	}
+
 	return &Queue[T]{
 		task:      initQueue(),
 		queueName: queueName,
+		options:   options,
 	}
 }
 
@@ -44,12 +96,14 @@ func (q *Queue[T]) WithData(data *T) {
 // This method may create duplicate jobs if called multiple times with the same data.
 // Use DispatchUnique() if you want to prevent duplicates.
 //
-// Returns an error if the job could not be enqueued (e.g., Redis connection error).
+// Returns an error if the payload could not be encoded or the job could not be enqueued
+// (e.g., Redis connection error).
 func (q *Queue[T]) Dispatch() error {
-	_, err := q.task.enqueue.Enqueue(q.queueName, work.Q{
-		"payload": q.serialize(),
-	})
-	return err
+	payload, encoding, err := q.serialize()
+	if err != nil {
+		return err
+	}
+	return q.task.dispatch(q.queueName, payload, encoding, false)
 }
 
 // DispatchUnique adds a unique job to the queue.
@@ -61,25 +115,69 @@ func (q *Queue[T]) Dispatch() error {
 //   - Useful for idempotent operations
 //   - Reduces unnecessary work
 //
-// Returns an error if the job could not be enqueued.
+// Returns an error if the payload could not be encoded or the job could not be enqueued.
 func (q *Queue[T]) DispatchUnique() error {
-	_, err := q.task.enqueue.EnqueueUnique(q.queueName, work.Q{
-		"payload": q.serialize(),
-	})
-	return err
+	payload, encoding, err := q.serialize()
+	if err != nil {
+		return err
+	}
+	return q.task.dispatch(q.queueName, payload, encoding, true)
 }
 
-// serialize converts the payload to JSON string for storage in Redis.
-// The payload is stored as JSON so it can be deserialized by workers.
+// DispatchIn adds a job to the queue that runs after the delay (rounded up to the second).
+// Returns the job ID, to find the job in Admin.ScheduledJobs.
 //
-// WHY JSON?
+// Example:
+//   // Send a reminder 24h after the install
+//   q.WithData(&Reminder{Shop: shop})
+//   id, err := q.DispatchIn(24 * time.Hour)
+//
+// Returns ErrNotSupported with the Watermill provider.
+func (q *Queue[T]) DispatchIn(d time.Duration) (string, error) {
+	return q.DispatchAt(time.Now().Add(d))
+}
+
+// DispatchAt adds a job to the queue that runs at the given time, a past time runs it now.
+// Returns the job ID, to find the job in Admin.ScheduledJobs.
+//
+// Returns ErrNotSupported with the Watermill provider.
+func (q *Queue[T]) DispatchAt(t time.Time) (string, error) {
+	payload, encoding, err := q.serialize()
+	if err != nil {
+		return "", err
+	}
+	return q.task.schedule(q.queueName, payload, encoding, t, false)
+}
+
+// DispatchUniqueIn adds a unique job to the queue that runs after the delay.
+// If a job with the same payload is waiting, no job is added and the job ID is empty.
+//
+// Returns ErrNotSupported with the Watermill provider.
+func (q *Queue[T]) DispatchUniqueIn(d time.Duration) (string, error) {
+	return q.DispatchUniqueAt(time.Now().Add(d))
+}
+
+// DispatchUniqueAt adds a unique job to the queue that runs at the given time.
+// If a job with the same payload is waiting, no job is added and the job ID is empty.
+//
+// Returns ErrNotSupported with the Watermill provider.
+func (q *Queue[T]) DispatchUniqueAt(t time.Time) (string, error) {
+	payload, encoding, err := q.serialize()
+	if err != nil {
+		return "", err
+	}
+	return q.task.schedule(q.queueName, payload, encoding, t, true)
+}
+
+// serialize encodes the payload with the codec of the queue, for storage in Redis.
+// It returns the payload and its encoding, stored with the job so workers know how to decode it.
+//
+// WHY JSON by default?
 //   - Human-readable format (useful for debugging)
 //   - Language-agnostic (can be read by other services)
-//   - Standard format supported by Redis
-//
-// NOTE: Errors during marshaling are ignored (returns empty string).
-// In production, you might want to handle this error explicitly.
-func (q *Queue[T]) serialize() string {
-	b, _ := json.Marshal(q.payload)
-	return string(b)
-}
\ No newline at end of file
+//   - Plain JSON jobs have the format of the jobs before codecs
+//
+// Use MsgpackCodec/ProtobufCodec and compression for large payloads.
+func (q *Queue[T]) serialize() (string, string, error) {
+	return encodePayload(q.options.Codec, q.options.CompressAbove, q.payload)
+}
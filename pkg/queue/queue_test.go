@@ -61,7 +61,14 @@ func TestQueue_Serialize(t *testing.T) {
 	}
 
 	queue.WithData(testData)
-	serialized := queue.serialize()
+	serialized, encoding, err := queue.serialize()
+	if err != nil {
+		t.Fatalf("Serialize should not return error, got %v", err)
+	}
+
+	if encoding != "" {
+		t.Errorf("Expected plain JSON without encoding, got %q", encoding)
+	}
 
 	if serialized == "" {
 		t.Error("Serialized payload should not be empty")
@@ -117,21 +124,21 @@ func TestWorker_Deserialize(t *testing.T) {
 	
 	jsonData := `{"id":1,"name":"Test User","email":"test@example.com"}`
 	
-	err := worker.deserialize(jsonData)
+	payload, err := worker.deserialize(jsonData, "")
 	if err != nil {
 		t.Errorf("Deserialize should not return error, got %v", err)
 	}
 
-	if worker.payload == nil {
-		t.Error("Worker payload should not be nil after deserialize")
+	if payload == nil {
+		t.Fatal("Payload should not be nil after deserialize")
 	}
 
-	if worker.payload.ID != 1 {
-		t.Errorf("Expected payload ID 1, got %d", worker.payload.ID)
+	if payload.ID != 1 {
+		t.Errorf("Expected payload ID 1, got %d", payload.ID)
 	}
 
-	if worker.payload.Name != "Test User" {
-		t.Errorf("Expected payload Name 'Test User', got '%s'", worker.payload.Name)
+	if payload.Name != "Test User" {
+		t.Errorf("Expected payload Name 'Test User', got '%s'", payload.Name)
 	}
 }
 
@@ -140,7 +147,7 @@ func TestWorker_Deserialize_InvalidJSON(t *testing.T) {
 	
 	invalidJSON := `{"id":1,"name":invalid}`
 	
-	err := worker.deserialize(invalidJSON)
+	_, err := worker.deserialize(invalidJSON, "")
 	if err == nil {
 		t.Error("Deserialize should return error for invalid JSON")
 	}
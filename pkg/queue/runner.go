@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultGracePeriod is the time the jobs have to finish before their context is cancelled.
+	defaultGracePeriod = 20 * time.Second
+	// defaultDrainTimeout is the time the jobs have to finish before the Runner gives up on them,
+	// below the 30s termination grace period of Kubernetes.
+	defaultDrainTimeout = 25 * time.Second
+)
+
+// RunnerOptions contains configuration for the Runner.
+type RunnerOptions struct {
+	GracePeriod  time.Duration // Time before the job contexts are cancelled (default: 20s)
+	DrainTimeout time.Duration // Time before the running jobs are abandoned (default: 25s)
+	Signals      []os.Signal   // Signals that start the shutdown (default: SIGTERM, SIGINT)
+}
+
+// WithGracePeriod returns an option function to set the time the jobs have to finish
+// after a shutdown starts, before their context is cancelled.
+func WithGracePeriod(d time.Duration) func(option *RunnerOptions) {
+	return func(option *RunnerOptions) {
+		option.GracePeriod = d
+	}
+}
+
+// WithDrainTimeout returns an option function to set the time the jobs have to finish
+// after a shutdown starts, before the Runner returns without them.
+func WithDrainTimeout(d time.Duration) func(option *RunnerOptions) {
+	return func(option *RunnerOptions) {
+		option.DrainTimeout = d
+	}
+}
+
+// WithSignals returns an option function to set the signals that start the shutdown.
+func WithSignals(signals ...os.Signal) func(option *RunnerOptions) {
+	return func(option *RunnerOptions) {
+		option.Signals = signals
+	}
+}
+
+// ShutdownReport lists the jobs interrupted by the shutdown.
+type ShutdownReport struct {
+	Cancelled []JobInfo // Jobs still running after the grace period, their context was cancelled
+	Abandoned []JobInfo // Jobs still running at the drain deadline
+}
+
+// runnable is a Worker with its handler, as run by the Runner
+type runnable interface {
+	run()
+	Stop()
+	cancel()
+	inFlight() []JobInfo
+}
+
+// workerRunnable binds a Worker to its handler
+type workerRunnable[T any] struct {
+	worker  *Worker[T]
+	handler func(ctx context.Context, data *T) error
+}
+
+func (r *workerRunnable[T]) run()                { r.worker.RunWithContext(r.handler) }
+func (r *workerRunnable[T]) Stop()               { r.worker.Stop() }
+func (r *workerRunnable[T]) cancel()             { r.worker.cancelJobs() }
+func (r *workerRunnable[T]) inFlight() []JobInfo { return r.worker.inFlight() }
+
+// Runner runs many workers and shuts them down gracefully on SIGTERM.
+//
+// HOW the shutdown works:
+//  1. The workers stop fetching new jobs
+//  2. After the grace period, the contexts of the running jobs are cancelled
+//  3. After the drain timeout, Run returns without waiting for the remaining jobs
+//
+// WHY?
+//   - A Kubernetes rollout sends SIGTERM, then SIGKILL after terminationGracePeriodSeconds (30s)
+//   - A job killed mid-way is retried by the queue, a job that sees its context cancelled can stop cleanly
+//
+// Example:
+//
+//	runner := queue.NewRunner(queue.WithGracePeriod(20*time.Second), queue.WithDrainTimeout(25*time.Second))
+//	queue.AddWorker(runner, queue.NewWorker[Email]("email"), sendEmail)
+//	queue.AddWorker(runner, queue.NewWorker[Order]("order"), syncOrder)
+//
+//	report := runner.Run(context.Background()) // Blocks until SIGTERM
+//	for _, job := range report.Abandoned {
+//	    slog.Warn("job interrupted", "queue", job.Queue, "id", job.ID)
+//	}
+type Runner struct {
+	options *RunnerOptions
+	mu      sync.Mutex
+	workers []runnable
+}
+
+// NewRunner creates a new Runner.
+func NewRunner(ops ...func(option *RunnerOptions)) *Runner {
+	options := &RunnerOptions{
+		GracePeriod:  defaultGracePeriod,
+		DrainTimeout: defaultDrainTimeout,
+		Signals:      []os.Signal{syscall.SIGTERM, os.Interrupt},
+	}
+	for _, op := range ops {
+		op(options)
+	}
+
+	return &Runner{options: options}
+}
+
+// AddWorker adds a worker with its handler to the Runner, before Run.
+func AddWorker[T any](r *Runner, worker *Worker[T], handler func(ctx context.Context, data *T) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workers = append(r.workers, &workerRunnable[T]{worker: worker, handler: handler})
+}
+
+// Run starts the workers and blocks until a signal is received or the context is cancelled,
+// then shuts the workers down and returns the interrupted jobs.
+func (r *Runner) Run(ctx context.Context) *ShutdownReport {
+	r.mu.Lock()
+	workers := r.workers
+	r.mu.Unlock()
+
+	ctx, stop := signal.NotifyContext(ctx, r.options.Signals...)
+	defer stop()
+
+	for _, w := range workers {
+		go w.run()
+	}
+
+	<-ctx.Done()
+	slog.Info("queue: shutting down workers", "workers", len(workers),
+		"grace_period", r.options.GracePeriod, "drain_timeout", r.options.DrainTimeout)
+	return r.shutdown(workers)
+}
+
+// shutdown stops the workers and waits for their jobs
+func (r *Runner) shutdown(workers []runnable) *ShutdownReport {
+	report := &ShutdownReport{}
+
+	// Stop waits for the running jobs
+	drained := make(chan struct{})
+	go func() {
+		wg := sync.WaitGroup{}
+		for _, w := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.Stop()
+			}()
+		}
+		wg.Wait()
+		close(drained)
+	}()
+
+	grace := time.NewTimer(r.options.GracePeriod)
+	defer grace.Stop()
+	drain := time.NewTimer(r.options.DrainTimeout)
+	defer drain.Stop()
+
+	for {
+		select {
+		case <-drained:
+			slog.Info("queue: workers stopped")
+			return report
+
+		case <-grace.C:
+			for _, w := range workers {
+				report.Cancelled = append(report.Cancelled, w.inFlight()...)
+				w.cancel()
+			}
+			if len(report.Cancelled) > 0 {
+				slog.Warn("queue: grace period over, cancelling jobs", "jobs", len(report.Cancelled))
+			}
+
+		case <-drain.C:
+			for _, w := range workers {
+				report.Abandoned = append(report.Abandoned, w.inFlight()...)
+			}
+			for _, job := range report.Abandoned {
+				slog.Error("queue: job abandoned at shutdown", "queue", job.Queue, "job", job.ID, "attempt", job.Attempt)
+			}
+			return report
+		}
+	}
+}
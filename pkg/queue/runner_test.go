@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// dispatchOne dispatches a job to the queue
+func dispatchOne(t *testing.T, queueName string) {
+	t.Helper()
+	q := NewQueue[TestPayload](queueName)
+	q.WithData(&TestPayload{ID: 1})
+	if err := q.Dispatch(); err != nil {
+		t.Fatalf("Dispatch returned error: %v", err)
+	}
+}
+
+// runRunner runs the runner until started is closed, then cancels it and returns the report
+func runRunner(t *testing.T, runner *Runner, started <-chan struct{}) *ShutdownReport {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	reports := make(chan *ShutdownReport, 1)
+	go func() { reports <- runner.Run(ctx) }()
+
+	select {
+	case <-started:
+	case <-time.After(15 * time.Second):
+		t.Fatal("timeout waiting for the job")
+	}
+	cancel()
+
+	select {
+	case report := <-reports:
+		return report
+	case <-time.After(15 * time.Second):
+		t.Fatal("Run did not return")
+		return nil
+	}
+}
+
+func TestRunner_Drain(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		dispatchOne(t, queueName)
+
+		started := make(chan struct{})
+		var finished atomic.Bool
+		runner := NewRunner(WithGracePeriod(5*time.Second), WithDrainTimeout(10*time.Second))
+		AddWorker(runner, NewWorker[TestPayload](queueName), func(ctx context.Context, data *TestPayload) error {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			finished.Store(ctx.Err() == nil)
+			return nil
+		})
+
+		report := runRunner(t, runner, started)
+		if !finished.Load() {
+			t.Error("Expected the job to finish within the grace period")
+		}
+		if len(report.Cancelled) != 0 || len(report.Abandoned) != 0 {
+			t.Errorf("Expected no interrupted job, got %+v", report)
+		}
+	})
+}
+
+func TestRunner_GracePeriod(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		dispatchOne(t, queueName)
+
+		started := make(chan struct{})
+		var cancelled atomic.Bool
+		runner := NewRunner(WithGracePeriod(100*time.Millisecond), WithDrainTimeout(10*time.Second))
+		AddWorker(runner, NewWorker[TestPayload](queueName, WithMaxFails(1), WithSkipDead()), func(ctx context.Context, data *TestPayload) error {
+			close(started)
+			<-ctx.Done()
+			cancelled.Store(true)
+			return ctx.Err()
+		})
+
+		report := runRunner(t, runner, started)
+		if !cancelled.Load() {
+			t.Error("Expected the job context to be cancelled after the grace period")
+		}
+		if len(report.Cancelled) != 1 || report.Cancelled[0].Queue != queueName || len(report.Abandoned) != 0 {
+			t.Errorf("Expected one cancelled job, got %+v", report)
+		}
+	})
+}
+
+func TestRunner_DrainTimeout(t *testing.T) {
+	providers(t, func(t *testing.T, queueName string) {
+		dispatchOne(t, queueName)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		t.Cleanup(func() { close(release) })
+
+		runner := NewRunner(WithGracePeriod(50*time.Millisecond), WithDrainTimeout(200*time.Millisecond))
+		AddWorker(runner, NewWorker[TestPayload](queueName), func(ctx context.Context, data *TestPayload) error {
+			close(started)
+			<-release // Ignores its context
+			return nil
+		})
+
+		start := time.Now()
+		report := runRunner(t, runner, started)
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Expected Run to return at the drain deadline, took %v", elapsed)
+		}
+		if len(report.Abandoned) != 1 || report.Abandoned[0].ID == "" {
+			t.Errorf("Expected one abandoned job, got %+v", report)
+		}
+	})
+}
+
+func TestRunner_Signal(t *testing.T) {
+	runner := NewRunner(WithSignals(syscall.SIGUSR1))
+	reports := make(chan *ShutdownReport, 1)
+	go func() { reports <- runner.Run(context.Background()) }()
+
+	time.Sleep(100 * time.Millisecond)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("Kill returned error: %v", err)
+	}
+
+	select {
+	case <-reports:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return on the signal")
+	}
+}
+
+func TestRunner_DrainAcknowledged(t *testing.T) {
+	// A job still pending after the restart is taken over quickly
+	previousIdle, previousInterval := watermillClaimIdle, watermillClaimInterval
+	watermillClaimIdle, watermillClaimInterval = 300*time.Millisecond, 100*time.Millisecond
+	t.Cleanup(func() { watermillClaimIdle, watermillClaimInterval = previousIdle, previousInterval })
+
+	providers(t, func(t *testing.T, queueName string) {
+		dispatchOne(t, queueName)
+
+		started := make(chan struct{})
+		var calls atomic.Int32
+		handler := func(ctx context.Context, data *TestPayload) error {
+			if calls.Add(1) == 1 {
+				close(started)
+				time.Sleep(200 * time.Millisecond)
+			}
+			return nil
+		}
+
+		runner := NewRunner(WithGracePeriod(5*time.Second), WithDrainTimeout(10*time.Second))
+		AddWorker(runner, NewWorker[TestPayload](queueName), handler)
+		if report := runRunner(t, runner, started); len(report.Cancelled) != 0 || len(report.Abandoned) != 0 {
+			t.Fatalf("Expected no interrupted job, got %+v", report)
+		}
+
+		// The job finished during the drain, the restarted worker must not run it again
+		runWorker(t, NewWorker[TestPayload](queueName), handler)
+		time.Sleep(time.Second)
+		if n := calls.Load(); n != 1 {
+			t.Errorf("Expected the job to run once, ran %d times", n)
+		}
+	})
+}
@@ -0,0 +1,357 @@
+package queue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var (
+	// watermillRetryInterval is the first delay before retrying a failed job, it doubles after each retry.
+	watermillRetryInterval = time.Second
+	// watermillMaxRetryInterval is the maximum delay before retrying a failed job.
+	watermillMaxRetryInterval = time.Minute
+	// watermillClaimIdle is the time a job is pending before another worker takes it over, e.g. after a crash.
+	watermillClaimIdle = redisstream.DefaultMaxIdleTime
+	// watermillClaimInterval is the interval between the checks for the jobs pending for watermillClaimIdle.
+	watermillClaimInterval = redisstream.DefaultClaimInterval
+	// watermillUniqueTTL is the expiry of the lock of a unique job, like the gocraft/work unique keys,
+	// so that a job lost before a worker takes it (e.g. trimmed stream) doesn't lock its payload forever.
+	watermillUniqueTTL = 24 * time.Hour
+
+	// watermillClient is the singleton go-redis client of the Watermill provider.
+	watermillClient *goredis.Client
+	// watermillClientOnce ensures the client is created only once (thread-safe).
+	watermillClientOnce sync.Once
+
+	// watermillLogger sends the Watermill logs to slog, its info logs are debug logs here.
+	watermillLogger watermill.LoggerAdapter = watermillLogAdapter{watermill.NewSlogLoggerWithLevelMapping(nil, map[slog.Level]slog.Level{
+		slog.LevelInfo: slog.LevelDebug,
+	})}
+)
+
+// watermillLogAdapter logs the errors of a shutdown (cancelled reads) as debug logs
+type watermillLogAdapter struct {
+	watermill.LoggerAdapter
+}
+
+func (l watermillLogAdapter) Error(msg string, err error, fields watermill.LogFields) {
+	if errors.Is(err, context.Canceled) {
+		l.LoggerAdapter.Debug(msg, fields.Add(watermill.LogFields{"err": err}))
+		return
+	}
+	l.LoggerAdapter.Error(msg, err, fields)
+}
+
+func (l watermillLogAdapter) With(fields watermill.LogFields) watermill.LoggerAdapter {
+	return watermillLogAdapter{l.LoggerAdapter.With(fields)}
+}
+
+// instanceClient returns the singleton go-redis client used to dispatch with Watermill.
+func instanceClient() *goredis.Client {
+	watermillClientOnce.Do(func() {
+		watermillClient = newClientRedis(0)
+	})
+	return watermillClient
+}
+
+// newClientRedis creates a go-redis client, 0 is the default pool size.
+// It reads the same environment variables as newPoolRedis.
+func newClientRedis(poolSize int) *goredis.Client {
+	dbNumber, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
+	return goredis.NewClient(&goredis.Options{
+		Addr:     fmt.Sprintf("%s:%s", os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT")),
+		DB:       dbNumber,
+		Password: os.Getenv("REDIS_PASSWORD"),
+		PoolSize: poolSize,
+	})
+}
+
+// watermillTopic returns the Redis stream of a queue
+func watermillTopic(queueName string) string {
+	return namespace + ":stream:" + queueName
+}
+
+// watermillDeadTopic returns the Redis stream of the dead jobs of a queue
+func watermillDeadTopic(queueName string) string {
+	return namespace + ":dead:" + queueName
+}
+
+// watermillUniqueKey returns the lock key of a unique job
+func watermillUniqueKey(queueName, payload string) string {
+	sum := sha256.Sum256([]byte(payload))
+	return namespace + ":unique:" + queueName + ":" + hex.EncodeToString(sum[:])
+}
+
+// newWatermillPublisher creates the Redis Streams publisher.
+// NewPublisher only fails without client.
+func newWatermillPublisher() message.Publisher {
+	publisher, _ := redisstream.NewPublisher(redisstream.PublisherConfig{
+		Client: instanceClient(),
+	}, watermillLogger)
+	return publisher
+}
+
+// publishWatermill adds a job to the stream of the queue.
+// A unique job takes a lock on its payload, released when a worker takes the job or after watermillUniqueTTL,
+// it is dropped if the lock is already taken (like gocraft/work EnqueueUnique).
+func (t *Task) publishWatermill(queueName, payload, encoding string, unique bool) error {
+	msg := message.NewMessage(watermill.NewUUID(), []byte(payload))
+	msg.Metadata.Set("enqueued_at", strconv.FormatInt(time.Now().Unix(), 10))
+	if encoding != "" {
+		msg.Metadata.Set("encoding", encoding)
+	}
+
+	if unique {
+		key := watermillUniqueKey(queueName, payload)
+		ok, err := instanceClient().SetNX(context.Background(), key, msg.UUID, watermillUniqueTTL).Result()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		msg.Metadata.Set("unique", key)
+	}
+
+	err := t.publisher.Publish(watermillTopic(queueName), msg)
+	if err != nil && unique {
+		releaseUnique(queueName, msg.Metadata.Get("unique"))
+	}
+	return err
+}
+
+// releaseUnique deletes the lock of a unique job that was not published
+func releaseUnique(queueName, key string) {
+	if err := instanceClient().Del(context.Background(), key).Err(); err != nil {
+		slog.Error("queue: failed to release unique job", "queue", queueName, "err", err)
+	}
+}
+
+// watermillWorker consumes a queue from Redis Streams with a Watermill router.
+//
+// HOW it maps the Options:
+//   - MaxConcurrency: one subscriber per concurrent job, in the consumer group of the queue
+//   - MaxFails: retries with exponential backoff, then the job is moved to the dead stream
+//   - SkipDead: the job is dropped after MaxFails instead of being moved
+//   - Priority: not supported, the jobs are consumed in order
+//
+// WHY a client per worker?
+//   - Each subscriber blocks a connection while it waits for jobs
+//   - The shared client would run out of connections with a few workers
+//
+// HOW stop drains the jobs:
+//  1. The jobs received after stop are not run, they stay pending for the next worker
+//  2. The running jobs finish and the subscribers acknowledge them
+//  3. The last job of each subscriber is acknowledged again, a subscriber closing drops the acknowledgement in flight
+//  4. The subscribers close
+type watermillWorker struct {
+	mu        sync.Mutex
+	router    *message.Router
+	client    *goredis.Client // Client of the subscribers
+	topic     string          // Stream of the queue
+	group     string          // Consumer group of the queue
+	consumers []string        // Consumer of each subscriber
+	lastDone  []string        // UUID of the last job done by each subscriber
+	running   sync.WaitGroup  // Jobs running
+	stopped   bool
+}
+
+// run consumes the queue until stop is called
+func (w *watermillWorker) run(queueName string, options *Options, handler func(job *JobInfo, payload, encoding string) error) error {
+	router, err := message.NewRouter(message.RouterConfig{}, watermillLogger)
+	if err != nil {
+		return err
+	}
+
+	// The first middleware is the outermost: it runs once the retries are exhausted
+	var middlewares []message.HandlerMiddleware
+	if options.SkipDead {
+		middlewares = append(middlewares, dropFailed(queueName))
+	} else {
+		poison, err := middleware.PoisonQueue(newWatermillPublisher(), watermillDeadTopic(queueName))
+		if err != nil {
+			return err
+		}
+		middlewares = append(middlewares, poison)
+	}
+	if options.MaxFails > 1 {
+		middlewares = append(middlewares, middleware.Retry{
+			MaxRetries:      int(options.MaxFails) - 1,
+			InitialInterval: watermillRetryInterval,
+			MaxInterval:     watermillMaxRetryInterval,
+			Multiplier:      2,
+			Logger:          watermillLogger,
+		}.Middleware)
+	}
+
+	concurrency := int(options.MaxConcurrency)
+	if concurrency == 0 {
+		concurrency = int(maxConcurrent)
+	}
+
+	// A blocking read and a claim of the pending jobs per subscriber.
+	// Each subscriber closes its client, they share one that is closed once the router stopped.
+	client := newClientRedis(2*concurrency + 1)
+	defer client.Close()
+
+	w.client, w.topic, w.group = client, watermillTopic(queueName), namespace+":"+queueName
+	w.consumers, w.lastDone = make([]string, concurrency), make([]string, concurrency)
+	consumer := watermill.NewShortUUID()
+	for i := 0; i < concurrency; i++ {
+		w.consumers[i] = consumer + "-" + strconv.Itoa(i)
+		subscriber, err := redisstream.NewSubscriber(redisstream.SubscriberConfig{
+			Client:        sharedClient{client},
+			Consumer:      w.consumers[i],
+			ConsumerGroup: w.group,
+			MaxIdleTime:   watermillClaimIdle,
+			ClaimInterval: watermillClaimInterval,
+			// Stop doesn't wait for a job forever
+			DisableIndefiniteInitialBlock: true,
+		}, watermillLogger)
+		if err != nil {
+			return err
+		}
+
+		router.AddConsumerHandler(queueName+"-"+strconv.Itoa(i), watermillTopic(queueName), subscriber,
+			func(msg *message.Message) error {
+				if key := msg.Metadata.Get("unique"); key != "" {
+					if err := client.Del(context.Background(), key).Err(); err != nil {
+						slog.Error("queue: failed to release unique job", "queue", queueName, "err", err)
+					}
+					msg.Metadata.Set("unique", "")
+				}
+
+				// The retries run the handler with the same message
+				attempt, _ := strconv.ParseInt(msg.Metadata.Get("attempt"), 10, 64)
+				attempt++
+				msg.Metadata.Set("attempt", strconv.FormatInt(attempt, 10))
+
+				enqueuedAt, _ := strconv.ParseInt(msg.Metadata.Get("enqueued_at"), 10, 64)
+				return handler(&JobInfo{
+					ID:         msg.UUID,
+					Queue:      queueName,
+					Attempt:    attempt,
+					EnqueuedAt: unixTime(enqueuedAt),
+				}, string(msg.Payload), msg.Metadata.Get("encoding"))
+			}).AddMiddleware(append([]message.HandlerMiddleware{w.gate(i)}, middlewares...)...)
+	}
+
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return nil
+	}
+	w.router = router
+	w.mu.Unlock()
+
+	return router.Run(context.Background())
+}
+
+// stop stops taking jobs, waits for the running jobs and closes the router
+func (w *watermillWorker) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	router := w.router
+	w.mu.Unlock()
+
+	if router == nil {
+		return
+	}
+
+	w.running.Wait()
+	w.ackLastDone()
+	if err := router.Close(); err != nil {
+		slog.Error("queue: failed to stop watermill router", "err", err)
+	}
+}
+
+// gate runs the jobs of the subscriber until stop is called, it is the outermost middleware.
+// A job received after is not run: it waits for the subscriber to close, and stays pending for the next worker.
+func (w *watermillWorker) gate(subscriber int) message.HandlerMiddleware {
+	return func(h message.HandlerFunc) message.HandlerFunc {
+		return func(msg *message.Message) ([]*message.Message, error) {
+			w.mu.Lock()
+			if w.stopped {
+				w.mu.Unlock()
+				<-msg.Context().Done()
+				return nil, msg.Context().Err()
+			}
+			w.running.Add(1)
+			w.mu.Unlock()
+			defer w.running.Done()
+
+			msgs, err := h(msg)
+			if err == nil {
+				// The router acknowledges the job
+				w.mu.Lock()
+				w.lastDone[subscriber] = msg.UUID
+				w.mu.Unlock()
+			}
+			return msgs, err
+		}
+	}
+}
+
+// ackLastDone acknowledges the last job done by each subscriber, if it is still pending.
+// A subscriber acknowledges a job after its handler returns, a subscriber closing at the same time
+// drops the acknowledgement and the job would run again.
+func (w *watermillWorker) ackLastDone() {
+	ctx := context.Background()
+	for i, consumer := range w.consumers {
+		if w.lastDone[i] == "" {
+			continue
+		}
+
+		pending, err := w.client.XPendingExt(ctx, &goredis.XPendingExtArgs{
+			Stream: w.topic, Group: w.group, Start: "-", End: "+", Count: 10, Consumer: consumer,
+		}).Result()
+		if err != nil {
+			slog.Error("queue: failed to read pending jobs", "stream", w.topic, "err", err)
+			return
+		}
+		for _, p := range pending {
+			msgs, err := w.client.XRange(ctx, w.topic, p.ID, p.ID).Result()
+			if err != nil || len(msgs) == 0 || msgs[0].Values[redisstream.UUIDHeaderKey] != w.lastDone[i] {
+				continue
+			}
+			if err = w.client.XAck(ctx, w.topic, w.group, p.ID).Err(); err != nil {
+				slog.Error("queue: failed to acknowledge job", "stream", w.topic, "err", err)
+			}
+		}
+	}
+}
+
+// sharedClient is a client shared by the subscribers, its Close is a no-op
+type sharedClient struct {
+	goredis.UniversalClient
+}
+
+func (sharedClient) Close() error { return nil }
+
+// dropFailed acknowledges the jobs that still fail after the retries, for SkipDead
+func dropFailed(queueName string) message.HandlerMiddleware {
+	return func(h message.HandlerFunc) message.HandlerFunc {
+		return func(msg *message.Message) ([]*message.Message, error) {
+			msgs, err := h(msg)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.Warn("queue: job dropped after max fails", "queue", queueName, "err", err)
+				return nil, nil
+			}
+			return msgs, err
+		}
+	}
+}
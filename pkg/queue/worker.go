@@ -2,7 +2,8 @@ package queue
 
 import (
 	"context"
-	"encoding/json"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/gocraft/work"
@@ -15,6 +16,9 @@ const (
 	defaultMaxConcurrency = 10
 )
 
+// workBackoff overrides the gocraft/work delay before retrying a failed job, nil for the default.
+var workBackoff work.BackoffCalculator
+
 // Worker processes jobs from a queue.
 // It consumes jobs dispatched by Queue and executes them using a worker pool.
 //
@@ -26,9 +30,16 @@ const (
 //   })
 type Worker[T any] struct {
 	queueName string          // Name of the queue to consume from
-	pool      *work.WorkerPool // Worker pool that processes jobs
-	payload   *T              // Current job payload (deserialized from queue)
+	pool      *workPool        // Worker pool of the namespace that processes jobs (gocraft/work provider)
+	watermill *watermillWorker // Router that processes jobs (Watermill provider)
 	options   *Options        // Worker configuration options
+
+	jobsCtx    context.Context    // Parent of the job contexts, cancelled by the Runner
+	cancelJobs context.CancelFunc // Cancels the job contexts
+	running    sync.Map           // Jobs being processed (*JobInfo)
+
+	mu      sync.Mutex // Serializes the start and stop of the pool
+	stopped bool       // Stop was called, the pool must not start
 }
 
 // NewWorker creates a new Worker instance for processing jobs from the specified queue.
@@ -50,12 +61,20 @@ func NewWorker[T any](queueName string, ops ...func(options *Options)) *Worker[T
 		op(options)
 	}
 
-	return &Worker[T]{
-		queueName: queueName,
-		pool:      work.NewWorkerPool(context.Background(), maxConcurrent, namespace, instancePool()),
-		payload:   new(T),
-		options:   options,
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	worker := &Worker[T]{
+		queueName:  queueName,
+		options:    options,
+		jobsCtx:    jobsCtx,
+		cancelJobs: cancelJobs,
 	}
+
+	if poolProvider() == poolWaterMill {
+		worker.watermill = &watermillWorker{}
+	} else {
+		worker.pool = sharedWorkPool(workNamespace(queueName))
+	}
+	return worker
 }
 
 // RunWithContext starts processing jobs from the queue.
@@ -68,6 +87,9 @@ func NewWorker[T any](queueName string, ops ...func(options *Options)) *Worker[T
 // The context passed to the handler function:
 //   - Can be cancelled if MaxTimeout is set
 //   - Should be checked for cancellation: if ctx.Done() is closed, stop processing
+//   - Carries the job metadata, see JobFromContext
+//
+// Each job is decoded into its own payload, the concurrent jobs don't share data.
 //
 // Example:
 //   worker.RunWithContext(func(ctx context.Context, data *MyPayload) error {
@@ -79,41 +101,87 @@ func NewWorker[T any](queueName string, ops ...func(options *Options)) *Worker[T
 //       return processData(data)
 //   })
 func (w *Worker[T]) RunWithContext(f func(ctx context.Context, data *T) error) {
-	// Register the job handler with the worker pool
-	w.pool.JobWithOptions(w.queueName, w.getOptions(), func(job *work.Job) error {
+	handler := func(job *JobInfo, payload, encoding string) error {
+		w.running.Store(job, struct{}{})
+		defer w.running.Delete(job)
+
 		// Get context (with timeout if configured)
 		ctxWorker, cancel := w.getContext()
 		defer cancel() // Always cancel to free resources
 
-		// Create new payload instance for this job
-		w.payload = new(T)
-		
 		// Deserialize payload from job arguments
-		if err := w.deserialize(job.ArgString("payload")); err != nil {
+		data, err := w.deserialize(payload, encoding)
+		if err != nil {
 			return err // Return error to trigger retry logic
 		}
 
 		// Call the user-provided handler
-		return f(ctxWorker, w.payload)
-	})
+		return f(withJob(ctxWorker, job), data)
+	}
+
+	if w.watermill != nil {
+		// Run the router (this blocks until Stop() is called)
+		if err := w.watermill.run(w.queueName, w.options, handler); err != nil {
+			slog.Error("queue: watermill worker stopped", "queue", w.queueName, "err", err)
+		}
+		return
+	}
 
-	// Start the worker pool (this blocks until Stop() is called)
-	w.pool.Start()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+
+	// Register the job handler with the worker pool, it starts the pool
+	w.pool.register(workRegistration{
+		owner:   w,
+		name:    w.queueName,
+		options: w.getOptions(),
+		handler: func(job *work.Job) error {
+			return handler(&JobInfo{
+				ID:         job.ID,
+				Queue:      w.queueName,
+				Attempt:    job.Fails + 1,
+				EnqueuedAt: unixTime(job.EnqueuedAt),
+			}, job.ArgString("payload"), job.ArgString("encoding"))
+		},
+	})
 }
 
 // Stop gracefully stops the worker pool.
 // Stops accepting new jobs and waits for current jobs to finish.
 // Should be called during application shutdown.
+//
+// NOTE: with gocraft/work, the workers of a namespace share a pool, Stop also waits for their running jobs.
 func (w *Worker[T]) Stop() {
-	w.pool.Stop()
+	if w.watermill != nil {
+		w.watermill.stop()
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	w.pool.release(w)
 }
 
-// deserialize converts the JSON string payload back to the typed struct.
-// This is the reverse of Queue.serialize().
+// inFlight returns the jobs being processed
+func (w *Worker[T]) inFlight() []JobInfo {
+	var jobs []JobInfo
+	w.running.Range(func(key, _ any) bool {
+		jobs = append(jobs, *key.(*JobInfo))
+		return true
+	})
+	return jobs
+}
+
+// deserialize converts the payload back to the typed struct, with the codec of its encoding
+// (plain JSON if empty). This is the reverse of Queue.serialize().
 //
-// Returns an error if JSON is invalid or doesn't match the expected type.
-func (w *Worker[T]) deserialize(data string) error {
-	return json.Unmarshal([]byte(data), &w.payload)
+// Returns a new payload for each job, or an error if the payload is invalid or doesn't match the expected type.
+func (w *Worker[T]) deserialize(data, encoding string) (*T, error) {
+	return decodePayload[T](data, encoding)
 }
 
 // getOptions converts internal Options to gocraft/work JobOptions.
@@ -137,19 +205,23 @@ func (w *Worker[T]) getOptions() work.JobOptions {
 		ops.MaxConcurrency = w.options.MaxConcurrency
 	}
 
+	if workBackoff != nil {
+		ops.Backoff = workBackoff
+	}
+
 	return ops
 }
 
 // getContext returns a context for job processing.
 // If MaxTimeout is set, returns a context with timeout.
-// Otherwise, returns a background context with a no-op cancel function.
+// Otherwise, returns the worker context (cancelled by the Runner) with a no-op cancel function.
 //
 // WHY timeout?
 //   - Prevents jobs from running indefinitely
 //   - Allows graceful cancellation of long-running jobs
 //   - Protects against resource leaks
 func (w *Worker[T]) getContext() (context.Context, context.CancelFunc) {
-	ctx := context.Background()
+	ctx := w.jobsCtx
 
 	// Set timeout if configured
 	if w.options.MaxTimeout > 0 {
@@ -158,6 +230,6 @@ func (w *Worker[T]) getContext() (context.Context, context.CancelFunc) {
 		return ctx, cancel
 	}
 
-	// No timeout - return background context with no-op cancel
+	// No timeout - return the worker context with no-op cancel
 	return ctx, func() {}
 }
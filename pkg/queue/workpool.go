@@ -0,0 +1,98 @@
+package queue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gocraft/work"
+)
+
+var (
+	// workPools are the gocraft/work worker pools by namespace, shared by the workers of the process.
+	workPools = map[string]*workPool{}
+	// workPoolsMu guards workPools.
+	workPoolsMu sync.Mutex
+)
+
+// workPool is the gocraft/work worker pool of a namespace, it runs the jobs of every worker of the namespace.
+//
+// WHY one pool per namespace?
+//   - The requeuers of a pool move the scheduled and retried jobs back to their queue
+//   - A pool moves the jobs of a queue it doesn't know to the dead set ("unknown job when requeueing")
+//   - The pool of a namespace knows every queue of the process, no job is lost to another queue's pool
+//
+// gocraft/work can't remove a job from a started pool: the pool is rebuilt when a worker starts or stops,
+// the rebuild waits for the running jobs of the namespace.
+type workPool struct {
+	mu        sync.Mutex
+	namespace string
+	pool      *work.WorkerPool   // Running pool, nil without worker
+	jobs      []workRegistration // Jobs of the running workers, in start order
+}
+
+// workRegistration is the job of a worker registered on the pool
+type workRegistration struct {
+	owner   any // Worker that registered the job
+	name    string
+	options work.JobOptions
+	handler func(job *work.Job) error
+}
+
+// sharedWorkPool returns the worker pool of the namespace
+func sharedWorkPool(namespace string) *workPool {
+	workPoolsMu.Lock()
+	defer workPoolsMu.Unlock()
+
+	pool, ok := workPools[namespace]
+	if !ok {
+		pool = &workPool{namespace: namespace}
+		workPools[namespace] = pool
+	}
+	return pool
+}
+
+// register adds the job of the worker and restarts the pool
+func (p *workPool) register(job workRegistration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.jobs = append(p.jobs, job)
+	p.restart()
+}
+
+// release removes the job of the worker and restarts the pool, it waits for the running jobs
+func (p *workPool) release(owner any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	jobs := p.jobs[:0]
+	for _, job := range p.jobs {
+		if job.owner != owner {
+			jobs = append(jobs, job)
+		}
+	}
+	if len(jobs) == len(p.jobs) {
+		return
+	}
+	p.jobs = jobs
+	p.restart()
+}
+
+// restart stops the pool and starts a new one with the registered jobs.
+// Each job keeps the concurrency of a pool of its own.
+func (p *workPool) restart() {
+	if p.pool != nil {
+		p.pool.Stop()
+		p.pool = nil
+	}
+	if len(p.jobs) == 0 {
+		return
+	}
+
+	p.pool = work.NewWorkerPool(context.Background(), maxConcurrent*uint(len(p.jobs)), p.namespace, instancePool())
+	for _, job := range p.jobs {
+		// A later worker of the same queue replaces the handler
+		p.pool.JobWithOptions(job.name, job.options, job.handler)
+	}
+	p.pool.Start()
+}
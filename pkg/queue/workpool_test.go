@@ -0,0 +1,46 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkPool_SharedNamespace(t *testing.T) {
+	// A namespace of its own, the dead set is empty
+	appNamespace(t, "app"+strconv.FormatInt(time.Now().UnixNano(), 10))
+	queues := []string{adminQueue(t), adminQueue(t) + "-other"}
+
+	for _, queueName := range queues {
+		q := NewQueue[TestPayload](queueName)
+		for i := range 5 {
+			q.WithData(&TestPayload{ID: i})
+			if _, err := q.DispatchIn(time.Second); err != nil {
+				t.Fatalf("DispatchIn returned error: %v", err)
+			}
+		}
+	}
+
+	// The scheduled and retried jobs of each queue are requeued by the pool of the namespace
+	var ran atomic.Int32
+	for _, queueName := range queues {
+		var failed atomic.Bool
+		runWorker(t, NewWorker[TestPayload](queueName), func(ctx context.Context, data *TestPayload) error {
+			if data.ID == 0 && !failed.Swap(true) {
+				return errors.New("boom")
+			}
+			ran.Add(1)
+			return nil
+		})
+	}
+
+	waitFor(t, func() bool { return ran.Load() == 10 })
+	for _, queueName := range queues {
+		if _, total, _ := NewAdmin[TestPayload](queueName).DeadJobs(1); total != 0 {
+			t.Errorf("Expected no dead job in %s, got %d", queueName, total)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+const (
+	// TopicAppSubscriptionsUpdate is sent when the status of a subscription changes.
+	TopicAppSubscriptionsUpdate = "app_subscriptions/update"
+
+	// IntervalEvery30Days bills a recurring plan every 30 days.
+	IntervalEvery30Days = "EVERY_30_DAYS"
+	// IntervalAnnual bills a recurring plan every year.
+	IntervalAnnual = "ANNUAL"
+
+	// SubscriptionActive is the status of an approved, billed subscription.
+	SubscriptionActive = "ACTIVE"
+	// SubscriptionCancelled is the status of a subscription cancelled by the app or the uninstall.
+	SubscriptionCancelled = "CANCELLED"
+	// SubscriptionFrozen is the status of a subscription of a shop that is paused or behind on payment.
+	SubscriptionFrozen = "FROZEN"
+	// SubscriptionDeclined is the status of a subscription the merchant didn't approve.
+	SubscriptionDeclined = "DECLINED"
+	// SubscriptionPending is the status of a subscription waiting for the merchant's approval.
+	SubscriptionPending = "PENDING"
+)
+
+// ErrUnknownPlan is returned when a plan name was not registered with WithPlan.
+var ErrUnknownPlan = errors.New("shopify: unknown plan")
+
+// Plan is a subscription plan of the app.
+// A plan has a recurring price, a usage price (UsageCappedAmount > 0), or both.
+type Plan struct {
+	Name              string  // Shown to the merchant, also used to find the plan
+	Amount            float64 // Recurring price, 0 for a usage-only plan
+	CurrencyCode      string  // Default: USD
+	Interval          string  // IntervalEvery30Days (default) or IntervalAnnual
+	TrialDays         int     // Free trial
+	UsageCappedAmount float64 // Maximum usage charges per 30 days, 0 for no usage price
+	UsageTerms        string  // Description of the usage charges, e.g. "$0.01 per order"
+}
+
+// lineItems returns the AppSubscriptionLineItemInput of the plan
+func (p *Plan) lineItems() []map[string]any {
+	currency := p.CurrencyCode
+	if currency == "" {
+		currency = "USD"
+	}
+	interval := p.Interval
+	if interval == "" {
+		interval = IntervalEvery30Days
+	}
+
+	var items []map[string]any
+	if p.Amount > 0 {
+		items = append(items, map[string]any{"plan": map[string]any{
+			"appRecurringPricingDetails": map[string]any{
+				"price":    map[string]any{"amount": p.Amount, "currencyCode": currency},
+				"interval": interval,
+			},
+		}})
+	}
+	if p.UsageCappedAmount > 0 {
+		items = append(items, map[string]any{"plan": map[string]any{
+			"appUsagePricingDetails": map[string]any{
+				"cappedAmount": map[string]any{"amount": p.UsageCappedAmount, "currencyCode": currency},
+				"terms":        p.UsageTerms,
+			},
+		}})
+	}
+	return items
+}
+
+// AppSubscription is a subscription of a shop.
+type AppSubscription struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Status           string    `json:"status"`
+	Test             bool      `json:"test"`
+	TrialDays        int       `json:"trialDays"`
+	CurrentPeriodEnd time.Time `json:"currentPeriodEnd"`
+	LineItems        []struct {
+		ID   string `json:"id"`
+		Plan struct {
+			PricingDetails struct {
+				Typename string `json:"__typename"` // AppRecurringPricing or AppUsagePricing
+			} `json:"pricingDetails"`
+		} `json:"plan"`
+	} `json:"lineItems"`
+}
+
+// UsageLineItemID returns the ID of the usage line item, for CreateUsageRecord.
+// Empty if the plan has no usage price.
+func (s *AppSubscription) UsageLineItemID() string {
+	for _, item := range s.LineItems {
+		if item.Plan.PricingDetails.Typename == "AppUsagePricing" {
+			return item.ID
+		}
+	}
+	return ""
+}
+
+// BillingOptions contains configuration for the billing module.
+type BillingOptions struct {
+	plans map[string]Plan // Plans by name
+	test  bool            // Create test charges (development stores)
+}
+
+// WithPlan returns an option function to add a plan.
+//
+// Example:
+//
+//	billing := shopify.NewBilling(returnURL,
+//	    shopify.WithPlan(shopify.Plan{Name: "Basic", Amount: 9.99, TrialDays: 7}),
+//	    shopify.WithPlan(shopify.Plan{Name: "Pro", Amount: 29.99, UsageCappedAmount: 100, UsageTerms: "$0.01 per order"}),
+//	)
+func WithPlan(plan Plan) func(option *BillingOptions) {
+	return func(option *BillingOptions) {
+		option.plans[plan.Name] = plan
+	}
+}
+
+// WithTestCharges returns an option function to create test charges, that are not billed.
+// Use it for development stores.
+func WithTestCharges(test bool) func(option *BillingOptions) {
+	return func(option *BillingOptions) {
+		option.test = test
+	}
+}
+
+// Billing creates and checks the app subscriptions of the shops, with the default client.
+//
+// HOW it works:
+//  1. CreateSubscription creates a PENDING subscription and returns the confirmation URL
+//  2. The merchant approves it and is sent back to the return URL, the subscription is ACTIVE
+//  3. SubscriptionHandler receives the status changes (cancelled, frozen, ...)
+//  4. HasActivePlan guards the paid features
+type Billing struct {
+	returnURL string
+	options   *BillingOptions
+}
+
+// NewBilling creates a new billing module, the merchant is sent back to returnURL after approval.
+func NewBilling(returnURL string, ops ...func(option *BillingOptions)) *Billing {
+	options := &BillingOptions{plans: map[string]Plan{}}
+	for _, op := range ops {
+		op(options)
+	}
+
+	return &Billing{
+		returnURL: returnURL,
+		options:   options,
+	}
+}
+
+const appSubscriptionCreateMutation = `mutation appSubscriptionCreate($name: String!, $lineItems: [AppSubscriptionLineItemInput!]!, $returnUrl: URL!, $trialDays: Int, $test: Boolean) {
+  appSubscriptionCreate(name: $name, lineItems: $lineItems, returnUrl: $returnUrl, trialDays: $trialDays, test: $test) {
+    appSubscription { id status }
+    confirmationUrl
+    userErrors { field message }
+  }
+}`
+
+const activeSubscriptionsQuery = `query {
+  currentAppInstallation {
+    activeSubscriptions {
+      id name status test trialDays currentPeriodEnd
+      lineItems { id plan { pricingDetails { __typename } } }
+    }
+  }
+}`
+
+const appSubscriptionCancelMutation = `mutation appSubscriptionCancel($id: ID!) {
+  appSubscriptionCancel(id: $id) {
+    appSubscription { id status }
+    userErrors { field message }
+  }
+}`
+
+const appUsageRecordCreateMutation = `mutation appUsageRecordCreate($subscriptionLineItemId: ID!, $price: MoneyInput!, $description: String!, $idempotencyKey: String) {
+  appUsageRecordCreate(subscriptionLineItemId: $subscriptionLineItemId, price: $price, description: $description, idempotencyKey: $idempotencyKey) {
+    appUsageRecord { id }
+    userErrors { field message }
+  }
+}`
+
+// CreateSubscription creates a subscription to the plan and returns the URL to send the merchant to.
+// Returns ErrUnknownPlan if the plan was not added with WithPlan, UserErrors if Shopify rejects it.
+func (b *Billing) CreateSubscription(ctx context.Context, shop, accessToken, planName string) (string, error) {
+	plan, ok := b.options.plans[planName]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPlan, planName)
+	}
+
+	resp, err := Mutate[struct {
+		Create struct {
+			ConfirmationURL string `json:"confirmationUrl"`
+		} `json:"appSubscriptionCreate"`
+	}](ctx, shop, accessToken, appSubscriptionCreateMutation, map[string]any{
+		"name":      plan.Name,
+		"lineItems": plan.lineItems(),
+		"returnUrl": b.returnURL,
+		"trialDays": plan.TrialDays,
+		"test":      b.options.test,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	slog.Info("shopify: subscription created", "shop", shop, "plan", plan.Name)
+	return resp.Create.ConfirmationURL, nil
+}
+
+// ActiveSubscriptions returns the active subscriptions of the shop.
+func (b *Billing) ActiveSubscriptions(ctx context.Context, shop, accessToken string) ([]AppSubscription, error) {
+	resp, err := Query[struct {
+		Installation struct {
+			ActiveSubscriptions []AppSubscription `json:"activeSubscriptions"`
+		} `json:"currentAppInstallation"`
+	}](ctx, shop, accessToken, activeSubscriptionsQuery)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Installation.ActiveSubscriptions, nil
+}
+
+// HasActivePlan returns true if the shop has an ACTIVE subscription to one of the plans,
+// or to any plan if none is given.
+// Test subscriptions only count when WithTestCharges is set.
+//
+// Example:
+//
+//	if ok, err := billing.HasActivePlan(ctx, shop, token, "Pro"); err != nil || !ok {
+//	    return redirectToPricing()
+//	}
+func (b *Billing) HasActivePlan(ctx context.Context, shop, accessToken string, plans ...string) (bool, error) {
+	subscriptions, err := b.ActiveSubscriptions(ctx, shop, accessToken)
+	if err != nil {
+		return false, err
+	}
+
+	for _, subscription := range subscriptions {
+		if subscription.Status != SubscriptionActive || (subscription.Test && !b.options.test) {
+			continue
+		}
+		if len(plans) == 0 || slices.Contains(plans, subscription.Name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CancelSubscription cancels a subscription of the shop.
+func (b *Billing) CancelSubscription(ctx context.Context, shop, accessToken, subscriptionID string) error {
+	_, err := Mutate[struct{}](ctx, shop, accessToken, appSubscriptionCancelMutation, map[string]any{"id": subscriptionID})
+	return err
+}
+
+// CreateUsageRecord charges a usage amount on the usage line item of a subscription
+// (see AppSubscription.UsageLineItemID), in the currency of the plan.
+// idempotencyKey makes the retries of the same charge safe, it can be empty.
+func (b *Billing) CreateUsageRecord(ctx context.Context, shop, accessToken, lineItemID, description string, amount float64, currencyCode, idempotencyKey string) error {
+	variables := map[string]any{
+		"subscriptionLineItemId": lineItemID,
+		"price":                  map[string]any{"amount": amount, "currencyCode": currencyCode},
+		"description":            description,
+	}
+	if idempotencyKey != "" {
+		variables["idempotencyKey"] = idempotencyKey
+	}
+
+	_, err := Mutate[struct{}](ctx, shop, accessToken, appUsageRecordCreateMutation, variables)
+	return err
+}
+
+// AppSubscriptionUpdate is the payload of the app_subscriptions/update webhook.
+type AppSubscriptionUpdate struct {
+	AppSubscription struct {
+		ID           string    `json:"admin_graphql_api_id"`
+		ShopID       string    `json:"admin_graphql_api_shop_id"`
+		Name         string    `json:"name"`
+		Status       string    `json:"status"` // Uppercase, e.g. ACTIVE, CANCELLED, FROZEN
+		CappedAmount string    `json:"capped_amount"`
+		Currency     string    `json:"currency"`
+		CreatedAt    time.Time `json:"created_at"`
+		UpdatedAt    time.Time `json:"updated_at"`
+	} `json:"app_subscription"`
+}
+
+// SubscriptionHandler returns a webhook handler of the app_subscriptions/update webhooks,
+// register it for TopicAppSubscriptionsUpdate to keep the status of the subscriptions in sync.
+//
+// Example:
+//
+//	receiver.Handle(shopify.TopicAppSubscriptionsUpdate, shopify.SubscriptionHandler(
+//	    func(ctx context.Context, shop string, update *shopify.AppSubscriptionUpdate) error {
+//	        return db.SetPlanStatus(ctx, shop, update.AppSubscription.Name, update.AppSubscription.Status)
+//	    },
+//	))
+func SubscriptionHandler(handler func(ctx context.Context, shop string, update *AppSubscriptionUpdate) error) WebhookHandler {
+	return func(ctx context.Context, webhook *Webhook) error {
+		update := &AppSubscriptionUpdate{}
+		if err := json.Unmarshal(webhook.Body, update); err != nil {
+			return fmt.Errorf("failed to unmarshal %s webhook: %w", webhook.Topic, err)
+		}
+
+		slog.Info("shopify: subscription updated", "shop", webhook.Shop,
+			"plan", update.AppSubscription.Name, "status", update.AppSubscription.Status)
+		return handler(ctx, webhook.Shop, update)
+	}
+}
@@ -0,0 +1,98 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBilling_CreateSubscription(t *testing.T) {
+	var variables map[string]any
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]any `json:"variables"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		variables = req.Variables
+
+		w.Write([]byte(`{"data":{"appSubscriptionCreate":{"appSubscription":{"id":"1","status":"PENDING"},
+			"confirmationUrl":"https://abc.myshopify.com/admin/charges/confirm","userErrors":[]}}}`))
+	})
+	defer closeServer()
+	SetDefaultClient(client)
+	defer SetDefaultClient(NewClient())
+
+	billing := NewBilling("https://app.example.com/billing",
+		WithPlan(Plan{Name: "Pro", Amount: 29.99, TrialDays: 7, UsageCappedAmount: 100, UsageTerms: "$0.01 per order"}),
+		WithTestCharges(true),
+	)
+
+	ctx := context.Background()
+	if _, err := billing.CreateSubscription(ctx, "abc.myshopify.com", "token", "Gold"); !errors.Is(err, ErrUnknownPlan) {
+		t.Errorf("Expected ErrUnknownPlan, got %v", err)
+	}
+
+	confirmationURL, err := billing.CreateSubscription(ctx, "abc.myshopify.com", "token", "Pro")
+	if err != nil {
+		t.Fatalf("CreateSubscription returned error: %v", err)
+	}
+	if !strings.HasSuffix(confirmationURL, "/admin/charges/confirm") {
+		t.Errorf("Unexpected confirmation URL: %s", confirmationURL)
+	}
+
+	lineItems, _ := variables["lineItems"].([]any)
+	if len(lineItems) != 2 || variables["test"] != true || variables["trialDays"] != float64(7) {
+		t.Errorf("Unexpected variables: %v", variables)
+	}
+}
+
+func TestBilling_HasActivePlan(t *testing.T) {
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"currentAppInstallation":{"activeSubscriptions":[
+			{"id":"1","name":"Basic","status":"ACTIVE","test":false,"currentPeriodEnd":"2026-01-01T00:00:00Z",
+			"lineItems":[{"id":"li1","plan":{"pricingDetails":{"__typename":"AppRecurringPricing"}}},
+				{"id":"li2","plan":{"pricingDetails":{"__typename":"AppUsagePricing"}}}]},
+			{"id":"2","name":"Pro","status":"ACTIVE","test":true,"currentPeriodEnd":null,"lineItems":[]}]}}}`))
+	})
+	defer closeServer()
+	SetDefaultClient(client)
+	defer SetDefaultClient(NewClient())
+
+	ctx := context.Background()
+	billing := NewBilling("https://app.example.com/billing")
+
+	if ok, err := billing.HasActivePlan(ctx, "abc.myshopify.com", "token", "Basic"); err != nil || !ok {
+		t.Errorf("Expected active Basic plan, got %v %v", ok, err)
+	}
+	if ok, _ := billing.HasActivePlan(ctx, "abc.myshopify.com", "token", "Pro"); ok {
+		t.Error("Expected test Pro subscription to not count without test charges")
+	}
+
+	subscriptions, _ := billing.ActiveSubscriptions(ctx, "abc.myshopify.com", "token")
+	if len(subscriptions) != 2 || subscriptions[0].UsageLineItemID() != "li2" {
+		t.Errorf("Unexpected subscriptions: %+v", subscriptions)
+	}
+}
+
+func TestSubscriptionHandler(t *testing.T) {
+	var status string
+	receiver := NewWebhookReceiver("secret")
+	receiver.Handle(TopicAppSubscriptionsUpdate, SubscriptionHandler(func(ctx context.Context, shop string, update *AppSubscriptionUpdate) error {
+		status = shop + " " + update.AppSubscription.Status
+		return nil
+	}))
+
+	err := receiver.Dispatch(context.Background(), &Webhook{
+		Topic: TopicAppSubscriptionsUpdate,
+		Shop:  "abc.myshopify.com",
+		Body:  json.RawMessage(`{"app_subscription":{"admin_graphql_api_id":"gid://shopify/AppSubscription/1","name":"Pro","status":"FROZEN"}}`),
+	})
+	if err != nil || status != "abc.myshopify.com FROZEN" {
+		t.Errorf("Expected FROZEN status, got %q %v", status, err)
+	}
+}
@@ -0,0 +1,432 @@
+package shopify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// bulkPollInterval is the first delay between two status checks of a bulk operation,
+	// it doubles after each check up to bulkPollMaxInterval.
+	bulkPollInterval = time.Second
+	// bulkPollMaxInterval is the maximum delay between two status checks of a bulk operation.
+	bulkPollMaxInterval = 30 * time.Second
+	// bulkHTTPClient downloads the results and uploads the variables of bulk operations.
+	// No timeout: the files can be large, the context cancels the transfer.
+	bulkHTTPClient = &http.Client{}
+)
+
+// ErrBulkOperationFailed is returned when a bulk operation ends with a status other than COMPLETED.
+var ErrBulkOperationFailed = errors.New("shopify: bulk operation failed")
+
+// BulkOperation is the status of a bulk operation.
+type BulkOperation struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`    // CREATED, RUNNING, COMPLETED, CANCELING, CANCELED, FAILED, EXPIRED
+	ErrorCode      string `json:"errorCode"` // ACCESS_DENIED, INTERNAL_SERVER_ERROR, TIMEOUT
+	ObjectCount    string `json:"objectCount"`
+	URL            string `json:"url"`            // Results file, empty if there are no results
+	PartialDataURL string `json:"partialDataUrl"` // Partial results of a failed operation
+}
+
+// bulkRunResponse is the response of bulkOperationRunQuery and bulkOperationRunMutation
+type bulkRunResponse struct {
+	BulkOperation *BulkOperation `json:"bulkOperation"`
+	UserErrors    UserErrors     `json:"userErrors"`
+}
+
+const bulkRunQueryMutation = `mutation bulkOperationRunQuery($query: String!) {
+  bulkOperationRunQuery(query: $query) {
+    bulkOperation { id status }
+    userErrors { field message }
+  }
+}`
+
+const bulkRunMutationMutation = `mutation bulkOperationRunMutation($mutation: String!, $stagedUploadPath: String!) {
+  bulkOperationRunMutation(mutation: $mutation, stagedUploadPath: $stagedUploadPath) {
+    bulkOperation { id status }
+    userErrors { field message }
+  }
+}`
+
+const bulkOperationQuery = `query bulkOperation($id: ID!) {
+  node(id: $id) {
+    ... on BulkOperation { id status errorCode objectCount url partialDataUrl }
+  }
+}`
+
+const stagedUploadsCreateMutation = `mutation stagedUploadsCreate($input: [StagedUploadInput!]!) {
+  stagedUploadsCreate(input: $input) {
+    stagedTargets { url resourceUrl parameters { name value } }
+    userErrors { field message }
+  }
+}`
+
+// RunBulkQuery runs a bulk query with the default client and yields the records of the result.
+// Generic type T is the record struct of the top-level connection.
+//
+// HOW it works:
+//  1. Submits the query with bulkOperationRunQuery
+//  2. Polls the operation with backoff until it is COMPLETED
+//  3. Streams the JSONL result, rebuilding the nested connections
+//
+// The records of nested connections (lines with __parentId) are collected in the "__children"
+// field of their parent, add `__typename` to the query to tell the child types apart:
+//
+//	type Product struct {
+//	    ID       string `json:"id"`
+//	    Title    string `json:"title"`
+//	    Children []struct {
+//	        Typename string `json:"__typename"`
+//	        ID       string `json:"id"`
+//	        SKU      string `json:"sku"`
+//	    } `json:"__children"`
+//	}
+//
+//	for product, err := range shopify.RunBulkQuery[Product](ctx, shop, token, `{
+//	    products { edges { node { id title variants { edges { node { __typename id sku } } } } } }
+//	}`) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    // ...
+//	}
+func RunBulkQuery[T any](ctx context.Context, shop, accessToken, query string) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		var resp struct {
+			Run bulkRunResponse `json:"bulkOperationRunQuery"`
+		}
+		err := defaultClient.Do(ctx, shop, accessToken, bulkRunQueryMutation, map[string]any{"query": query}, &resp)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		tree := &bulkTree{}
+		stopped := false
+		emit := func(node *bulkNode) bool {
+			record := new(T)
+			if err := json.Unmarshal(node.marshal(), record); err != nil {
+				stopped = !yield(nil, fmt.Errorf("failed to unmarshal bulk record: %w", err))
+			} else {
+				stopped = !yield(record, nil)
+			}
+			return !stopped
+		}
+
+		err = readBulkResults(ctx, shop, accessToken, &resp.Run, func(line []byte) bool {
+			done, err := tree.add(line)
+			if err != nil {
+				stopped = !yield(nil, err)
+				return !stopped
+			}
+			if done != nil {
+				return emit(done)
+			}
+			return true
+		})
+		if stopped {
+			return
+		}
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if last := tree.root; last != nil {
+			emit(last)
+		}
+	}
+}
+
+// BulkMutationResult is the result of a mutation of a bulk mutation.
+type BulkMutationResult[T any] struct {
+	Line int // Index of the variables of the mutation (__lineNumber)
+	Data *T  // Response of the mutation, nil if it failed
+}
+
+// RunBulkMutation runs a bulk mutation with the default client, once per variables,
+// and yields the result of each mutation with the index of its variables.
+// Shopify doesn't keep the order of the variables, match the results with Line.
+// Generic type T is the response struct of the mutation (the "data" field of each result line).
+// A mutation that failed is yielded with its Line and the GraphQLErrors.
+// The variables are uploaded as JSONL through stagedUploadsCreate.
+//
+// Example:
+//
+//	variables := []any{
+//	    map[string]any{"input": map[string]any{"title": "Sweet new product"}},
+//	}
+//	for result, err := range shopify.RunBulkMutation[ProductCreateResp](ctx, shop, token,
+//	    `mutation call($input: ProductInput!) { productCreate(input: $input) { product { id } userErrors { message field } } }`,
+//	    variables) {
+//	    if result == nil {
+//	        return err
+//	    }
+//	    input := variables[result.Line]
+//	    // ...
+//	}
+func RunBulkMutation[T any](ctx context.Context, shop, accessToken, mutation string, variables []any) iter.Seq2[*BulkMutationResult[T], error] {
+	return func(yield func(*BulkMutationResult[T], error) bool) {
+		path, err := uploadBulkVariables(ctx, shop, accessToken, variables)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		var resp struct {
+			Run bulkRunResponse `json:"bulkOperationRunMutation"`
+		}
+		err = defaultClient.Do(ctx, shop, accessToken, bulkRunMutationMutation, map[string]any{
+			"mutation":         mutation,
+			"stagedUploadPath": path,
+		}, &resp)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		stopped := false
+		err = readBulkResults(ctx, shop, accessToken, &resp.Run, func(line []byte) bool {
+			var result struct {
+				Data       *T            `json:"data"`
+				Errors     GraphQLErrors `json:"errors"`
+				LineNumber int           `json:"__lineNumber"`
+			}
+			if err := json.Unmarshal(line, &result); err != nil {
+				stopped = !yield(nil, fmt.Errorf("failed to unmarshal bulk result: %w", err))
+			} else if len(result.Errors) > 0 {
+				stopped = !yield(&BulkMutationResult[T]{Line: result.LineNumber}, result.Errors)
+			} else {
+				stopped = !yield(&BulkMutationResult[T]{Line: result.LineNumber, Data: result.Data}, nil)
+			}
+			return !stopped
+		})
+		if err != nil && !stopped {
+			yield(nil, err)
+		}
+	}
+}
+
+// readBulkResults waits for the submitted operation and calls f for each line of the result,
+// until f returns false.
+func readBulkResults(ctx context.Context, shop, accessToken string, run *bulkRunResponse, f func(line []byte) bool) error {
+	if len(run.UserErrors) > 0 {
+		return fmt.Errorf("shopify: bulk operation rejected: %w", run.UserErrors)
+	}
+	if run.BulkOperation == nil {
+		return errors.New("shopify: no bulk operation returned")
+	}
+
+	op, err := WaitBulkOperation(ctx, shop, accessToken, run.BulkOperation.ID)
+	if err != nil {
+		return err
+	}
+	if op.URL == "" {
+		return nil // No results
+	}
+
+	return streamLines(ctx, op.URL, f)
+}
+
+// WaitBulkOperation polls a bulk operation with the default client until it is done,
+// the delay between two checks doubles from 1s up to 30s.
+// Returns ErrBulkOperationFailed (wrapped, with the status and error code) if it didn't complete.
+func WaitBulkOperation(ctx context.Context, shop, accessToken, id string) (*BulkOperation, error) {
+	interval := bulkPollInterval
+	for {
+		var resp struct {
+			Node *BulkOperation `json:"node"`
+		}
+		if err := defaultClient.Do(ctx, shop, accessToken, bulkOperationQuery, map[string]any{"id": id}, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Node == nil {
+			return nil, fmt.Errorf("shopify: bulk operation %s not found", id)
+		}
+
+		switch resp.Node.Status {
+		case "COMPLETED":
+			return resp.Node, nil
+		case "CANCELED", "FAILED", "EXPIRED":
+			return resp.Node, fmt.Errorf("%w: %s %s", ErrBulkOperationFailed, resp.Node.Status, resp.Node.ErrorCode)
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+		interval = min(interval*2, bulkPollMaxInterval)
+	}
+}
+
+// streamLines downloads the file and calls f for each non-empty line, until f returns false
+func streamLines(ctx context.Context, url string, f func(line []byte) bool) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := bulkHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download bulk results: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download bulk results: status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if !f(scanner.Bytes()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// bulkNode is a record of a bulk result with its nested records.
+type bulkNode struct {
+	fields   map[string]json.RawMessage
+	children []*bulkNode
+}
+
+// marshal returns the JSON of the record, with the nested records in "__children"
+func (n *bulkNode) marshal() []byte {
+	if len(n.children) > 0 {
+		children := make([]json.RawMessage, len(n.children))
+		for i, child := range n.children {
+			children[i] = child.marshal()
+		}
+		n.fields["__children"], _ = json.Marshal(children)
+	}
+	b, _ := json.Marshal(n.fields)
+	return b
+}
+
+// bulkTree rebuilds the nesting of the JSONL lines.
+// A nested record always follows its parent, so a top-level record is complete
+// when the next top-level record starts.
+type bulkTree struct {
+	root  *bulkNode            // Top-level record being built
+	index map[string]*bulkNode // Records of the root by ID
+}
+
+// add adds a line to the tree, it returns the previous top-level record if the line starts a new one
+func (t *bulkTree) add(line []byte) (*bulkNode, error) {
+	node := &bulkNode{}
+	if err := json.Unmarshal(line, &node.fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bulk line: %w", err)
+	}
+
+	var id, parentID string
+	_ = json.Unmarshal(node.fields["id"], &id)
+	_ = json.Unmarshal(node.fields["__parentId"], &parentID)
+
+	if parentID == "" {
+		done := t.root
+		t.root = node
+		t.index = map[string]*bulkNode{id: node}
+		return done, nil
+	}
+
+	parent, ok := t.index[parentID]
+	if !ok {
+		return nil, fmt.Errorf("shopify: bulk record %s has unknown parent %s", id, parentID)
+	}
+	parent.children = append(parent.children, node)
+	if id != "" {
+		t.index[id] = node
+	}
+	return nil, nil
+}
+
+// stagedTarget is a staged upload target
+type stagedTarget struct {
+	URL        string `json:"url"`
+	Parameters []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"parameters"`
+}
+
+// uploadBulkVariables uploads the variables as JSONL and returns the staged upload path
+func uploadBulkVariables(ctx context.Context, shop, accessToken string, variables []any) (string, error) {
+	var resp struct {
+		Create struct {
+			StagedTargets []stagedTarget `json:"stagedTargets"`
+			UserErrors    UserErrors     `json:"userErrors"`
+		} `json:"stagedUploadsCreate"`
+	}
+	err := defaultClient.Do(ctx, shop, accessToken, stagedUploadsCreateMutation, map[string]any{
+		"input": []map[string]string{{
+			"resource":   "BULK_MUTATION_VARIABLES",
+			"filename":   "bulk_op_vars.jsonl",
+			"mimeType":   "text/jsonl",
+			"httpMethod": "POST",
+		}},
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Create.UserErrors) > 0 {
+		return "", fmt.Errorf("shopify: staged upload rejected: %w", resp.Create.UserErrors)
+	}
+	if len(resp.Create.StagedTargets) == 0 {
+		return "", errors.New("shopify: no staged upload target returned")
+	}
+
+	var jsonl bytes.Buffer
+	encoder := json.NewEncoder(&jsonl)
+	for _, v := range variables {
+		if err = encoder.Encode(v); err != nil {
+			return "", fmt.Errorf("failed to marshal bulk variables: %w", err)
+		}
+	}
+
+	target := resp.Create.StagedTargets[0]
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	path := ""
+	for _, p := range target.Parameters {
+		_ = writer.WriteField(p.Name, p.Value)
+		if p.Name == "key" {
+			path = p.Value
+		}
+	}
+	part, _ := writer.CreateFormFile("file", "bulk_op_vars.jsonl")
+	_, _ = io.Copy(part, &jsonl)
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	uploadResp, err := bulkHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload bulk variables: %w", err)
+	}
+	defer uploadResp.Body.Close()
+
+	if uploadResp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(uploadResp.Body)
+		return "", fmt.Errorf("failed to upload bulk variables: status %d %s", uploadResp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return path, nil
+}
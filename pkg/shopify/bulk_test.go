@@ -0,0 +1,143 @@
+package shopify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunBulkQuery(t *testing.T) {
+	bulkPollInterval = time.Millisecond
+
+	var server *httptest.Server
+	polls := 0
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/result.jsonl" {
+			w.Write([]byte(`{"id":"gid://shopify/Product/1","title":"Shirt"}
+{"id":"gid://shopify/ProductVariant/11","sku":"S-1","__parentId":"gid://shopify/Product/1"}
+{"id":"gid://shopify/ProductVariant/12","sku":"S-2","__parentId":"gid://shopify/Product/1"}
+{"id":"gid://shopify/Product/2","title":"Hat"}
+`))
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.Contains(string(body), "bulkOperationRunQuery("):
+			w.Write([]byte(`{"data":{"bulkOperationRunQuery":{"bulkOperation":{"id":"gid://shopify/BulkOperation/1","status":"CREATED"},"userErrors":[]}}}`))
+		case polls == 0:
+			polls++
+			w.Write([]byte(`{"data":{"node":{"id":"gid://shopify/BulkOperation/1","status":"RUNNING"}}}`))
+		default:
+			w.Write([]byte(`{"data":{"node":{"id":"gid://shopify/BulkOperation/1","status":"COMPLETED","url":"` + server.URL + `/result.jsonl"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.makeURL = func(shop string) string { return server.URL + "/graphql.json" }
+	SetDefaultClient(client)
+	defer SetDefaultClient(NewClient())
+
+	type product struct {
+		ID       string `json:"id"`
+		Title    string `json:"title"`
+		Children []struct {
+			SKU string `json:"sku"`
+		} `json:"__children"`
+	}
+
+	var products []*product
+	for p, err := range RunBulkQuery[product](context.Background(), "abc.myshopify.com", "token", `{ products { edges { node { id } } } }`) {
+		if err != nil {
+			t.Fatalf("RunBulkQuery returned error: %v", err)
+		}
+		products = append(products, p)
+	}
+
+	if len(products) != 2 {
+		t.Fatalf("Expected 2 products, got %d", len(products))
+	}
+	if products[0].Title != "Shirt" || len(products[0].Children) != 2 || products[0].Children[1].SKU != "S-2" {
+		t.Errorf("Unexpected first product: %+v", products[0])
+	}
+	if products[1].Title != "Hat" || len(products[1].Children) != 0 {
+		t.Errorf("Unexpected second product: %+v", products[1])
+	}
+}
+
+func TestRunBulkMutation(t *testing.T) {
+	bulkPollInterval = time.Millisecond
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "/result.jsonl":
+			// Shopify doesn't keep the order of the variables
+			w.Write([]byte(`{"data":{"productCreate":{"product":{"id":"gid://shopify/Product/3"}}},"__lineNumber":2}
+{"errors":[{"message":"Invalid input"}],"__lineNumber":1}
+{"data":{"productCreate":{"product":{"id":"gid://shopify/Product/1"}}},"__lineNumber":0}
+`))
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.Contains(string(body), "stagedUploadsCreate("):
+			w.Write([]byte(`{"data":{"stagedUploadsCreate":{"stagedTargets":[{"url":"` + server.URL + `/upload","parameters":[{"name":"key","value":"tmp/vars.jsonl"}]}],"userErrors":[]}}}`))
+		case strings.Contains(string(body), "bulkOperationRunMutation("):
+			w.Write([]byte(`{"data":{"bulkOperationRunMutation":{"bulkOperation":{"id":"gid://shopify/BulkOperation/2","status":"CREATED"},"userErrors":[]}}}`))
+		default:
+			w.Write([]byte(`{"data":{"node":{"id":"gid://shopify/BulkOperation/2","status":"COMPLETED","url":"` + server.URL + `/result.jsonl"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.makeURL = func(shop string) string { return server.URL + "/graphql.json" }
+	SetDefaultClient(client)
+	defer SetDefaultClient(NewClient())
+
+	type productCreate struct {
+		ProductCreate struct {
+			Product struct {
+				ID string `json:"id"`
+			} `json:"product"`
+		} `json:"productCreate"`
+	}
+
+	variables := []any{map[string]any{"title": "A"}, map[string]any{"title": ""}, map[string]any{"title": "C"}}
+	ids := make([]string, len(variables))
+	for result, err := range RunBulkMutation[productCreate](context.Background(), "abc.myshopify.com", "token",
+		`mutation call($input: ProductInput!) { productCreate(input: $input) { product { id } } }`, variables) {
+		if result == nil {
+			t.Fatalf("RunBulkMutation returned error: %v", err)
+		}
+		if err != nil {
+			ids[result.Line] = "error"
+			continue
+		}
+		ids[result.Line] = result.Data.ProductCreate.Product.ID
+	}
+
+	want := []string{"gid://shopify/Product/1", "error", "gid://shopify/Product/3"}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("Variables %d: expected %q, got %q", i, want[i], ids[i])
+		}
+	}
+}
+
+func TestBulkTree_UnknownParent(t *testing.T) {
+	tree := &bulkTree{}
+	if _, err := tree.add([]byte(`{"id":"2","__parentId":"1"}`)); err == nil {
+		t.Error("Expected error for a record with unknown parent")
+	}
+}
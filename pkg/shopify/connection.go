@@ -0,0 +1,187 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// PageInfo is the pageInfo field of a GraphQL connection.
+type PageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// Edge is an edge of a GraphQL connection.
+type Edge[T any] struct {
+	Cursor string `json:"cursor"`
+	Node   T      `json:"node"`
+}
+
+// Connection is a GraphQL connection (e.g. products, orders, customers).
+// Generic type T is the node struct.
+// The query can select either `edges { node { ... } }` or `nodes { ... }`.
+type Connection[T any] struct {
+	Edges    []Edge[T] `json:"edges"`
+	Nodes    []T       `json:"nodes"`
+	PageInfo PageInfo  `json:"pageInfo"`
+}
+
+// Items returns the nodes of the page, from edges or nodes.
+func (c *Connection[T]) Items() []T {
+	if len(c.Edges) == 0 {
+		return c.Nodes
+	}
+
+	items := make([]T, len(c.Edges))
+	for i, edge := range c.Edges {
+		items[i] = edge.Node
+	}
+	return items
+}
+
+// PaginateOptions contains configuration for Paginate.
+type PaginateOptions struct {
+	variables map[string]any // Variables of the query, besides cursor
+	path      string         // Dot-separated path of the connection in data, empty for the only top-level field
+	maxItems  int            // Maximum number of items, 0 for all
+}
+
+// WithPageVariables returns an option function to set the variables of the query, besides cursor.
+//
+// Example:
+//
+//	shopify.Paginate[Order](ctx, shop, token, query, shopify.WithPageVariables(map[string]any{"query": "status:open"}))
+func WithPageVariables(variables map[string]any) func(option *PaginateOptions) {
+	return func(option *PaginateOptions) {
+		option.variables = variables
+	}
+}
+
+// WithConnectionPath returns an option function to set the dot-separated path of the connection
+// in the data field (e.g. "collection.products"), when it is not the only top-level field.
+func WithConnectionPath(path string) func(option *PaginateOptions) {
+	return func(option *PaginateOptions) {
+		option.path = path
+	}
+}
+
+// WithMaxItems returns an option function to stop after the given number of items.
+//
+// Example:
+//
+//	shopify.Paginate[Product](ctx, shop, token, query, shopify.WithMaxItems(500))
+func WithMaxItems(number int) func(option *PaginateOptions) {
+	return func(option *PaginateOptions) {
+		option.maxItems = number
+	}
+}
+
+// Paginate sends a connection query with the default client and yields the nodes of every page.
+// Generic type T is the node struct.
+// The query must have a `$cursor: String` variable passed as `after`, and select pageInfo.
+//
+// HOW it works:
+//  1. Sends the query with cursor = null
+//  2. Yields the nodes of the page, stops at the maximum number of items
+//  3. Sends the query again with cursor = pageInfo.endCursor while hasNextPage is true
+//
+// Every page goes through Client.Do, so the cost budget of the shop is respected between pages.
+//
+// Example:
+//
+//	type Product struct {
+//	    ID    string `json:"id"`
+//	    Title string `json:"title"`
+//	}
+//
+//	for product, err := range shopify.Paginate[Product](ctx, shop, token, `query ($cursor: String) {
+//	    products(first: 250, after: $cursor) {
+//	        nodes { id title }
+//	        pageInfo { hasNextPage endCursor }
+//	    }
+//	}`) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    // ...
+//	}
+func Paginate[T any](ctx context.Context, shop, accessToken, query string, ops ...func(option *PaginateOptions)) iter.Seq2[*T, error] {
+	options := &PaginateOptions{}
+	for _, op := range ops {
+		op(options)
+	}
+
+	return func(yield func(*T, error) bool) {
+		variables := map[string]any{}
+		for k, v := range options.variables {
+			variables[k] = v
+		}
+		variables["cursor"] = nil
+
+		count := 0
+		for {
+			var data json.RawMessage
+			if err := defaultClient.Do(ctx, shop, accessToken, query, variables, &data); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			page, err := decodeConnection[T](data, options.path)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			for _, item := range page.Items() {
+				if !yield(&item, nil) {
+					return
+				}
+				if count++; options.maxItems > 0 && count >= options.maxItems {
+					return
+				}
+			}
+
+			if !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == "" {
+				return
+			}
+			variables["cursor"] = page.PageInfo.EndCursor
+		}
+	}
+}
+
+// decodeConnection finds the connection at path in data and decodes it
+func decodeConnection[T any](data json.RawMessage, path string) (*Connection[T], error) {
+	if path == "" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("shopify: expected one top-level field, got %d, use WithConnectionPath", len(fields))
+		}
+		for _, field := range fields {
+			data = field
+		}
+	} else {
+		for _, key := range strings.Split(path, ".") {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %s: %w", key, err)
+			}
+			field, ok := fields[key]
+			if !ok {
+				return nil, fmt.Errorf("shopify: connection path %q not found in data", path)
+			}
+			data = field
+		}
+	}
+
+	connection := &Connection[T]{}
+	if err := json.Unmarshal(data, connection); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal connection: %w", err)
+	}
+	return connection, nil
+}
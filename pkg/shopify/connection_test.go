@@ -0,0 +1,86 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	var cursors []any
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]any `json:"variables"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		cursors = append(cursors, req.Variables["cursor"])
+
+		if req.Variables["status"] != "open" {
+			t.Errorf("Expected status variable, got %v", req.Variables)
+		}
+
+		if req.Variables["cursor"] == nil {
+			w.Write([]byte(`{"data":{"orders":{"edges":[{"cursor":"a","node":{"id":"1"}},{"cursor":"b","node":{"id":"2"}}],
+				"pageInfo":{"hasNextPage":true,"endCursor":"b"}}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"orders":{"edges":[{"cursor":"c","node":{"id":"3"}}],"pageInfo":{"hasNextPage":false,"endCursor":"c"}}}}`))
+	})
+	defer closeServer()
+	SetDefaultClient(client)
+	defer SetDefaultClient(NewClient())
+
+	type order struct {
+		ID string `json:"id"`
+	}
+
+	var ids []string
+	for o, err := range Paginate[order](context.Background(), "abc.myshopify.com", "token", `query`,
+		WithPageVariables(map[string]any{"status": "open"})) {
+		if err != nil {
+			t.Fatalf("Paginate returned error: %v", err)
+		}
+		ids = append(ids, o.ID)
+	}
+
+	if len(ids) != 3 || ids[0] != "1" || ids[2] != "3" {
+		t.Errorf("Expected ids [1 2 3], got %v", ids)
+	}
+	if len(cursors) != 2 || cursors[0] != nil || cursors[1] != "b" {
+		t.Errorf("Expected cursors [nil b], got %v", cursors)
+	}
+}
+
+func TestPaginate_MaxItemsAndPath(t *testing.T) {
+	calls := 0
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"data":{"collection":{"products":{"nodes":[{"id":"1"},{"id":"2"}],"pageInfo":{"hasNextPage":true,"endCursor":"x"}}}}}`))
+	})
+	defer closeServer()
+	SetDefaultClient(client)
+	defer SetDefaultClient(NewClient())
+
+	type product struct {
+		ID string `json:"id"`
+	}
+
+	count := 0
+	for _, err := range Paginate[product](context.Background(), "abc.myshopify.com", "token", `query`,
+		WithConnectionPath("collection.products"), WithMaxItems(3)) {
+		if err != nil {
+			t.Fatalf("Paginate returned error: %v", err)
+		}
+		count++
+	}
+
+	if count != 3 {
+		t.Errorf("Expected 3 items, got %d", count)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
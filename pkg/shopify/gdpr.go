@@ -0,0 +1,89 @@
+package shopify
+
+import (
+	"context"
+	"log/slog"
+)
+
+const (
+	// TopicCustomersDataRequest is sent when a customer requests their data from a shop.
+	TopicCustomersDataRequest = "customers/data_request"
+	// TopicCustomersRedact is sent when a shop requests the deletion of a customer's data.
+	TopicCustomersRedact = "customers/redact"
+	// TopicShopRedact is sent 48 hours after a shop uninstalls the app.
+	TopicShopRedact = "shop/redact"
+)
+
+// GDPRCustomer is the customer of a GDPR webhook.
+type GDPRCustomer struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// CustomersDataRequest is the payload of the customers/data_request webhook.
+type CustomersDataRequest struct {
+	ShopID          int64        `json:"shop_id"`
+	ShopDomain      string       `json:"shop_domain"`
+	OrdersRequested []int64      `json:"orders_requested"`
+	Customer        GDPRCustomer `json:"customer"`
+	DataRequest     struct {
+		ID int64 `json:"id"`
+	} `json:"data_request"`
+}
+
+// CustomersRedact is the payload of the customers/redact webhook.
+type CustomersRedact struct {
+	ShopID         int64        `json:"shop_id"`
+	ShopDomain     string       `json:"shop_domain"`
+	Customer       GDPRCustomer `json:"customer"`
+	OrdersToRedact []int64      `json:"orders_to_redact"`
+}
+
+// ShopRedact is the payload of the shop/redact webhook.
+type ShopRedact struct {
+	ShopID     int64  `json:"shop_id"`
+	ShopDomain string `json:"shop_domain"`
+}
+
+// GDPRHandlers are the callbacks of the mandatory GDPR webhooks.
+// A nil callback acknowledges the webhook without doing anything,
+// for apps that don't store the data concerned.
+type GDPRHandlers struct {
+	// ExportCustomer sends the data of the customer to the shop owner (within 30 days).
+	ExportCustomer func(ctx context.Context, request *CustomersDataRequest) error
+	// DeleteCustomer deletes the data of the customer (within 30 days).
+	DeleteCustomer func(ctx context.Context, request *CustomersRedact) error
+	// DeleteShop deletes the data of the shop.
+	DeleteShop func(ctx context.Context, request *ShopRedact) error
+}
+
+// RegisterGDPR registers the handlers of the mandatory GDPR webhooks on the receiver.
+// Point the compliance webhook URLs of the app to the receiver.
+//
+// Example:
+//
+//	shopify.RegisterGDPR(receiver, shopify.GDPRHandlers{
+//	    DeleteCustomer: func(ctx context.Context, request *shopify.CustomersRedact) error {
+//	        return db.DeleteCustomer(ctx, request.ShopDomain, request.Customer.ID)
+//	    },
+//	    DeleteShop: func(ctx context.Context, request *shopify.ShopRedact) error {
+//	        return db.DeleteShop(ctx, request.ShopDomain)
+//	    },
+//	})
+func RegisterGDPR(r *WebhookReceiver, handlers GDPRHandlers) {
+	registerGDPR(r, TopicCustomersDataRequest, handlers.ExportCustomer)
+	registerGDPR(r, TopicCustomersRedact, handlers.DeleteCustomer)
+	registerGDPR(r, TopicShopRedact, handlers.DeleteShop)
+}
+
+// registerGDPR registers a typed GDPR handler, or one that only logs the webhook if it is nil
+func registerGDPR[T any](r *WebhookReceiver, topic string, handler func(ctx context.Context, request *T) error) {
+	OnWebhook(r, topic, func(ctx context.Context, webhook *Webhook, payload *T) error {
+		slog.Info("shopify: GDPR webhook", "topic", topic, "shop", webhook.Shop)
+		if handler == nil {
+			return nil
+		}
+		return handler(ctx, payload)
+	})
+}
@@ -0,0 +1,37 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestRegisterGDPR(t *testing.T) {
+	var redacted *CustomersRedact
+	receiver := NewWebhookReceiver("secret")
+	RegisterGDPR(receiver, GDPRHandlers{
+		DeleteCustomer: func(ctx context.Context, request *CustomersRedact) error {
+			redacted = request
+			return nil
+		},
+	})
+
+	ctx := context.Background()
+	err := receiver.Dispatch(ctx, &Webhook{
+		Topic: TopicCustomersRedact,
+		Shop:  "abc.myshopify.com",
+		Body:  json.RawMessage(`{"shop_id":1,"shop_domain":"abc.myshopify.com","customer":{"id":42,"email":"a@b.c"},"orders_to_redact":[7,8]}`),
+	})
+	if err != nil {
+		t.Fatalf("Dispatch returned error: %v", err)
+	}
+	if redacted == nil || redacted.Customer.ID != 42 || len(redacted.OrdersToRedact) != 2 {
+		t.Errorf("Unexpected payload: %+v", redacted)
+	}
+
+	// Without callback the webhook is acknowledged
+	err = receiver.Dispatch(ctx, &Webhook{Topic: TopicShopRedact, Body: json.RawMessage(`{"shop_id":1}`)})
+	if err != nil {
+		t.Errorf("Expected nil error without callback, got %v", err)
+	}
+}
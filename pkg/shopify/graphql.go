@@ -0,0 +1,296 @@
+package shopify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tuyendt0112/golib/pkg/https"
+)
+
+const (
+	// defaultMaxRetries is the default maximum number of retries of a THROTTLED query.
+	defaultMaxRetries = 5
+	// defaultThrottledDelay is the delay before retrying a THROTTLED query without throttleStatus.
+	defaultThrottledDelay = time.Second
+	// maxQueryCosts is the maximum number of query costs kept by a Client.
+	maxQueryCosts = 1000
+)
+
+// GraphQLError is an error returned in the errors field of a GraphQL response.
+type GraphQLError struct {
+	Message    string `json:"message"`
+	Path       []any  `json:"path"`
+	Extensions struct {
+		Code string `json:"code"` // e.g. THROTTLED, ACCESS_DENIED
+	} `json:"extensions"`
+}
+
+// GraphQLErrors is the errors field of a GraphQL response.
+type GraphQLErrors []GraphQLError
+
+// Error returns the messages of the errors
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return "shopify graphql: " + strings.Join(messages, "; ")
+}
+
+// Is maps the errors to ErrGraphQL, and to ErrAccessDenied or ErrThrottled by code.
+//
+// Example:
+//
+//	if errors.Is(err, shopify.ErrAccessDenied) {
+//	    // ask the merchant to approve the missing scopes
+//	}
+func (e GraphQLErrors) Is(target error) bool {
+	switch target {
+	case ErrGraphQL:
+		return true
+	case ErrAccessDenied:
+		return e.hasCode("ACCESS_DENIED")
+	case ErrThrottled:
+		return e.hasCode("THROTTLED")
+	}
+	return false
+}
+
+// hasCode returns true if one of the errors has the given code
+func (e GraphQLErrors) hasCode(code string) bool {
+	for _, err := range e {
+		if err.Extensions.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// graphqlResponse is the body of a Shopify GraphQL response.
+type graphqlResponse struct {
+	Data       json.RawMessage `json:"data"`
+	Errors     GraphQLErrors   `json:"errors"`
+	Extensions struct {
+		Cost *Cost `json:"cost"`
+	} `json:"extensions"`
+}
+
+// Client sends GraphQL queries to the Shopify Admin API.
+// It keeps track of the cost budget of each shop, waits before a query whose
+// cost exceeds the available points and retries THROTTLED queries.
+//
+// WHY track the budget?
+//   - Shopify rejects the whole query with THROTTLED when the bucket is empty
+//   - Waiting before sending is cheaper than sending and retrying
+type Client struct {
+	options *Options
+	costs   queryCosts               // Last requested cost by query
+	makeURL func(shop string) string // Builds the GraphQL URL of the shop
+}
+
+// NewClient creates a new Shopify GraphQL client.
+//
+// Usage:
+//
+//	client := shopify.NewClient(
+//	    shopify.WithThrottleStore(shopify.NewRedisThrottleStore(redis.NewClientRedis())),
+//	    shopify.WithMaxRetries(10),
+//	)
+func NewClient(ops ...func(option *Options)) *Client {
+	options := &Options{
+		throttle:   NewMemoryThrottleStore(),
+		maxRetries: defaultMaxRetries,
+	}
+
+	for _, op := range ops {
+		op(options)
+	}
+
+	return &Client{
+		options: options,
+		makeURL: https.MakeShopifyGraphqlURL,
+	}
+}
+
+// defaultClient is the client used by the package-level functions.
+var defaultClient = NewClient()
+
+// SetDefaultClient sets the client used by the package-level functions (e.g. Query).
+func SetDefaultClient(client *Client) {
+	defaultClient = client
+}
+
+// Query sends a GraphQL query with the default client and returns the data field.
+// Generic type T is the data struct.
+// If there are no variables, you can omit the last argument.
+//
+// Example:
+//
+//	resp, err := shopify.Query[struct {
+//	    Shop struct{ Name string } `json:"shop"`
+//	}](ctx, "abc.myshopify.com", token, `{ shop { name } }`)
+func Query[T any](ctx context.Context, shop, accessToken, query string, variables ...any) (*T, error) {
+	var data T
+	var vars any
+	if len(variables) > 0 {
+		vars = variables[0]
+	}
+	err := defaultClient.Do(ctx, shop, accessToken, query, vars, &data)
+	return &data, err
+}
+
+// Do sends a GraphQL query to the shop and decodes the data field into data.
+// variables can be nil.
+//
+// HOW it works:
+//  1. Waits until the shop has enough points for the query (cost of its last run)
+//  2. Sends the query and saves the returned throttleStatus
+//  3. If the query is THROTTLED, waits for the missing points and retries
+//
+// Returns GraphQLErrors if the response has errors, match them with ErrGraphQL, ErrAccessDenied or ErrThrottled.
+func (c *Client) Do(ctx context.Context, shop, accessToken, query string, variables any, data any) error {
+	for attempt := 0; ; attempt++ {
+		if err := c.waitBudget(ctx, shop, query); err != nil {
+			return err
+		}
+
+		resp, err := c.send(shop, accessToken, query, variables)
+		if err != nil {
+			return err
+		}
+
+		cost := resp.Extensions.Cost
+		if cost != nil {
+			c.costs.store(query, cost.RequestedQueryCost)
+			c.saveStatus(ctx, shop, &cost.ThrottleStatus)
+		}
+
+		if resp.Errors.hasCode("THROTTLED") && attempt < c.options.maxRetries {
+			delay := defaultThrottledDelay
+			if cost != nil {
+				state := &ThrottleState{ThrottleStatus: cost.ThrottleStatus, UpdatedAt: time.Now()}
+				delay = state.Delay(cost.RequestedQueryCost, state.UpdatedAt)
+			}
+			slog.Warn("shopify: query throttled", "shop", shop, "attempt", attempt+1, "delay", delay)
+			if err = sleep(ctx, delay); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(resp.Errors) > 0 {
+			return resp.Errors
+		}
+
+		if data != nil && len(resp.Data) > 0 {
+			if err = json.Unmarshal(resp.Data, data); err != nil {
+				return fmt.Errorf("failed to unmarshal data: %w", err)
+			}
+		}
+		return nil
+	}
+}
+
+// send makes the HTTP request
+func (c *Client) send(shop, accessToken, query string, variables any) (*graphqlResponse, error) {
+	resp := &graphqlResponse{}
+	var reqVariables []any
+	if variables != nil {
+		reqVariables = append(reqVariables, variables)
+	}
+
+	options := append([]func(cfg *https.Options){
+		https.WithShopifyAccessToken(accessToken),
+		https.WithGraphQLReq(query, reqVariables...),
+		https.WithJSONRespTo(resp),
+	}, c.options.httpOptions...)
+
+	if err := https.Do(c.makeURL(shop), options...); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// queryCosts keeps the last requested cost of the queries, by SHA-256 of the query.
+// WHY bounded?
+//   - Queries built with inlined values are all different, the costs would grow forever
+//   - Past the limit an arbitrary cost is evicted, a missing cost only skips the wait
+type queryCosts struct {
+	mu    sync.Mutex
+	costs map[[sha256.Size]byte]float64
+}
+
+func (q *queryCosts) load(query string) (float64, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cost, ok := q.costs[sha256.Sum256([]byte(query))]
+	return cost, ok
+}
+
+func (q *queryCosts) store(query string, cost float64) {
+	key := sha256.Sum256([]byte(query))
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.costs == nil {
+		q.costs = map[[sha256.Size]byte]float64{}
+	}
+	if _, ok := q.costs[key]; !ok && len(q.costs) >= maxQueryCosts {
+		for k := range q.costs {
+			delete(q.costs, k)
+			break
+		}
+	}
+	q.costs[key] = cost
+}
+
+// waitBudget waits until the shop has enough points for the last known cost of the query
+func (c *Client) waitBudget(ctx context.Context, shop, query string) error {
+	cost, ok := c.costs.load(query)
+	if !ok {
+		return nil
+	}
+
+	state, err := c.options.throttle.Get(ctx, shop)
+	if err != nil {
+		// The budget is only an optimization, Shopify still throttles if needed
+		slog.Error("shopify: failed to get throttle state", "shop", shop, "err", err)
+		return nil
+	}
+	if state == nil {
+		return nil
+	}
+
+	return sleep(ctx, state.Delay(cost, time.Now()))
+}
+
+// saveStatus saves the throttleStatus returned by Shopify
+func (c *Client) saveStatus(ctx context.Context, shop string, status *ThrottleStatus) {
+	state := &ThrottleState{ThrottleStatus: *status, UpdatedAt: time.Now()}
+	if err := c.options.throttle.Set(ctx, shop, state); err != nil {
+		slog.Error("shopify: failed to save throttle state", "shop", shop, "err", err)
+	}
+}
+
+// sleep waits for the delay or until the context is done
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package shopify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestClient(handler http.HandlerFunc, ops ...func(option *Options)) (*Client, func()) {
+	server := httptest.NewServer(handler)
+	client := NewClient(ops...)
+	client.makeURL = func(shop string) string {
+		return server.URL + "/admin/api/graphql.json"
+	}
+	return client, server.Close
+}
+
+func TestClient_Do_RetryThrottled(t *testing.T) {
+	var calls int32
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Shopify-Access-Token") != "token" {
+			t.Error("Expected access token header")
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Write([]byte(`{"errors":[{"message":"Throttled","extensions":{"code":"THROTTLED"}}],
+				"extensions":{"cost":{"requestedQueryCost":100,"throttleStatus":{"maximumAvailable":1000,"currentlyAvailable":50,"restoreRate":1000}}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"shop":{"name":"Test"}},
+			"extensions":{"cost":{"requestedQueryCost":100,"actualQueryCost":1,"throttleStatus":{"maximumAvailable":1000,"currentlyAvailable":999,"restoreRate":1000}}}}`))
+	})
+	defer closeServer()
+
+	var data struct {
+		Shop struct{ Name string } `json:"shop"`
+	}
+	if err := client.Do(context.Background(), "abc.myshopify.com", "token", `{ shop { name } }`, nil, &data); err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+	if data.Shop.Name != "Test" {
+		t.Errorf("Expected shop name 'Test', got '%s'", data.Shop.Name)
+	}
+
+	state, _ := client.options.throttle.Get(context.Background(), "abc.myshopify.com")
+	if state == nil || state.CurrentlyAvailable != 999 {
+		t.Errorf("Expected saved throttle state, got %v", state)
+	}
+}
+
+func TestClient_Do_MaxRetries(t *testing.T) {
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":[{"message":"Throttled","extensions":{"code":"THROTTLED"}}]}`))
+	}, WithMaxRetries(0))
+	defer closeServer()
+
+	err := client.Do(context.Background(), "abc.myshopify.com", "token", `{ shop { name } }`, nil, nil)
+
+	var gqlErrs GraphQLErrors
+	if !errors.As(err, &gqlErrs) || !gqlErrs.hasCode("THROTTLED") {
+		t.Errorf("Expected THROTTLED error, got %v", err)
+	}
+}
+
+func TestQueryCosts_Bounded(t *testing.T) {
+	var costs queryCosts
+	for i := range maxQueryCosts + 10 {
+		costs.store(fmt.Sprintf(`{ product(id: "%d") { id } }`, i), float64(i))
+	}
+	if len(costs.costs) != maxQueryCosts {
+		t.Errorf("Expected %d costs, got %d", maxQueryCosts, len(costs.costs))
+	}
+
+	last := fmt.Sprintf(`{ product(id: "%d") { id } }`, maxQueryCosts+9)
+	if cost, ok := costs.load(last); !ok || cost != float64(maxQueryCosts+9) {
+		t.Errorf("Expected the last cost, got %v %v", cost, ok)
+	}
+}
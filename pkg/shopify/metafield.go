@@ -0,0 +1,420 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metafieldsSetLimit is the maximum number of metafields per metafieldsSet call.
+const metafieldsSetLimit = 25
+
+// ErrMetafieldType is returned when a Go value doesn't fit the metafield type.
+var ErrMetafieldType = errors.New("shopify: value doesn't match metafield type")
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// metafieldDateTimeLocal is the layout of a date_time without offset (ISO 8601), e.g. 2022-02-02T12:30:00,
+// Shopify returns it as well as RFC 3339.
+const metafieldDateTimeLocal = "2006-01-02T15:04:05"
+
+// Money is the value of a money metafield.
+type Money struct {
+	Amount       string `json:"amount"` // Decimal, e.g. "5.99"
+	CurrencyCode string `json:"currency_code"`
+}
+
+// Metafield is a metafield of a resource (product, order, customer, shop, ...).
+// Value is the string form sent and returned by Shopify, use Set and Decode to convert it
+// from and to Go values according to Type.
+//
+// Conversions:
+//   - single_line_text_field, multi_line_text_field, url, color, *_reference: string
+//   - number_integer: int types, number_decimal: float types (or string to keep the precision)
+//   - boolean: bool, date and date_time: time.Time
+//   - json, money (Money), rating, dimension, weight, volume, rich_text_field: JSON
+//   - list.<type>: slice of the Go type of <type>
+//
+// A string target always gets the raw value.
+type Metafield struct {
+	ID        string `json:"id,omitempty"`
+	OwnerID   string `json:"ownerId,omitempty"` // e.g. "gid://shopify/Product/1"
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Type      string `json:"type"` // e.g. "number_integer", "list.single_line_text_field"
+	Value     string `json:"value"`
+}
+
+// NewMetafield creates a metafield of the owner with the value encoded for the type.
+//
+// Example:
+//
+//	m, err := shopify.NewMetafield(productID, "custom", "sizes", "list.single_line_text_field", []string{"S", "M"})
+func NewMetafield(ownerID, namespace, key, metafieldType string, value any) (*Metafield, error) {
+	m := &Metafield{OwnerID: ownerID, Namespace: namespace, Key: key, Type: metafieldType}
+	if err := m.Set(value); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Set encodes the Go value into Value according to Type.
+func (m *Metafield) Set(value any) error {
+	base, isList := strings.CutPrefix(m.Type, "list.")
+	if !isList {
+		s, err := encodeMetafieldScalar(base, reflect.ValueOf(value))
+		if err != nil {
+			return err
+		}
+		m.Value = s
+		return nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("%w: %s needs a slice, got %T", ErrMetafieldType, m.Type, value)
+	}
+
+	items := make([]json.RawMessage, rv.Len())
+	for i := range items {
+		s, err := encodeMetafieldScalar(base, rv.Index(i))
+		if err != nil {
+			return err
+		}
+		if base == "number_integer" || isJSONMetafieldType(base) {
+			items[i] = json.RawMessage(s)
+		} else if items[i], err = json.Marshal(s); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	m.Value = string(b)
+	return nil
+}
+
+// Decode decodes Value into the Go value pointed to by v, according to Type.
+//
+// Example:
+//
+//	var sizes []string
+//	err := m.Decode(&sizes)
+func (m *Metafield) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: Decode needs a non-nil pointer, got %T", ErrMetafieldType, v)
+	}
+	target := rv.Elem()
+
+	base, isList := strings.CutPrefix(m.Type, "list.")
+	if !isList || target.Kind() == reflect.String {
+		return decodeMetafieldScalar(base, m.Value, target)
+	}
+
+	if target.Kind() != reflect.Slice {
+		return fmt.Errorf("%w: %s needs a slice, got %s", ErrMetafieldType, m.Type, target.Type())
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(m.Value), &items); err != nil {
+		return fmt.Errorf("failed to unmarshal %s: %w", m.Type, err)
+	}
+
+	slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+	for i, item := range items {
+		// Text items are JSON strings, numbers may be either
+		s := string(item)
+		if !isJSONMetafieldType(base) {
+			var str string
+			if json.Unmarshal(item, &str) == nil {
+				s = str
+			}
+		}
+		if err := decodeMetafieldScalar(base, s, slice.Index(i)); err != nil {
+			return err
+		}
+	}
+	target.Set(slice)
+	return nil
+}
+
+// isJSONMetafieldType returns true if the value of the type is a JSON object
+func isJSONMetafieldType(metafieldType string) bool {
+	switch metafieldType {
+	case "json", "money", "rating", "dimension", "weight", "volume", "rich_text_field", "link":
+		return true
+	}
+	return false
+}
+
+// encodeMetafieldScalar encodes a value of a non-list type
+func encodeMetafieldScalar(metafieldType string, v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", fmt.Errorf("%w: nil %s", ErrMetafieldType, metafieldType)
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "", fmt.Errorf("%w: nil %s", ErrMetafieldType, metafieldType)
+	}
+
+	if isJSONMetafieldType(metafieldType) {
+		if v.Kind() == reflect.String {
+			return v.String(), nil // Already JSON
+		}
+		b, err := json.Marshal(v.Interface())
+		return string(b), err
+	}
+
+	mismatch := fmt.Errorf("%w: %s can't be set from %s", ErrMetafieldType, metafieldType, v.Type())
+	switch metafieldType {
+	case "number_integer":
+		switch {
+		case v.CanInt():
+			return strconv.FormatInt(v.Int(), 10), nil
+		case v.CanUint():
+			return strconv.FormatUint(v.Uint(), 10), nil
+		case v.Kind() == reflect.String:
+			if _, err := strconv.ParseInt(v.String(), 10, 64); err != nil {
+				return "", mismatch
+			}
+			return v.String(), nil
+		}
+	case "number_decimal":
+		switch {
+		case v.CanFloat():
+			return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+		case v.CanInt():
+			return strconv.FormatInt(v.Int(), 10), nil
+		case v.Kind() == reflect.String:
+			if _, err := strconv.ParseFloat(v.String(), 64); err != nil {
+				return "", mismatch
+			}
+			return v.String(), nil
+		}
+	case "boolean":
+		if v.Kind() == reflect.Bool {
+			return strconv.FormatBool(v.Bool()), nil
+		}
+	case "date", "date_time":
+		if v.Type() == timeType {
+			if metafieldType == "date" {
+				return v.Interface().(time.Time).Format(time.DateOnly), nil
+			}
+			return v.Interface().(time.Time).Format(time.RFC3339), nil
+		}
+		if v.Kind() == reflect.String {
+			return v.String(), nil
+		}
+	default:
+		// Text, url, color, references: the value is the string
+		if v.Kind() == reflect.String {
+			return v.String(), nil
+		}
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			return s.String(), nil
+		}
+	}
+	return "", mismatch
+}
+
+// decodeMetafieldScalar decodes a value of a non-list type into target
+func decodeMetafieldScalar(metafieldType, value string, target reflect.Value) error {
+	if target.Kind() == reflect.Pointer {
+		target.Set(reflect.New(target.Type().Elem()))
+		target = target.Elem()
+	}
+	if target.Kind() == reflect.String {
+		target.SetString(value)
+		return nil
+	}
+
+	if isJSONMetafieldType(metafieldType) {
+		if err := json.Unmarshal([]byte(value), target.Addr().Interface()); err != nil {
+			return fmt.Errorf("failed to unmarshal %s: %w", metafieldType, err)
+		}
+		return nil
+	}
+
+	mismatch := fmt.Errorf("%w: %s can't be decoded into %s", ErrMetafieldType, metafieldType, target.Type())
+	switch metafieldType {
+	case "number_integer", "number_decimal":
+		switch {
+		case target.CanInt():
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || target.OverflowInt(n) {
+				return mismatch
+			}
+			target.SetInt(n)
+			return nil
+		case target.CanUint():
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || target.OverflowUint(n) {
+				return mismatch
+			}
+			target.SetUint(n)
+			return nil
+		case target.CanFloat():
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return mismatch
+			}
+			target.SetFloat(f)
+			return nil
+		}
+	case "boolean":
+		if target.Kind() == reflect.Bool {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return mismatch
+			}
+			target.SetBool(b)
+			return nil
+		}
+	case "date", "date_time":
+		if target.Type() == timeType {
+			layout := time.RFC3339
+			if metafieldType == "date" {
+				layout = time.DateOnly
+			}
+			t, err := time.Parse(layout, value)
+			if err != nil && metafieldType == "date_time" {
+				t, err = time.Parse(metafieldDateTimeLocal, value)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", metafieldType, err)
+			}
+			target.Set(reflect.ValueOf(t))
+			return nil
+		}
+	}
+	return mismatch
+}
+
+const metafieldsSetMutation = `mutation metafieldsSet($metafields: [MetafieldsSetInput!]!) {
+  metafieldsSet(metafields: $metafields) {
+    metafields { id namespace key type value owner { ... on Node { id } } }
+    userErrors { field message code }
+  }
+}`
+
+const metafieldQuery = `query metafield($ownerId: ID!, $namespace: String!, $key: String!) {
+  node(id: $ownerId) {
+    ... on HasMetafields {
+      metafield(namespace: $namespace, key: $key) { id namespace key type value }
+    }
+  }
+}`
+
+// SetMetafields sets the metafields with the default client, 25 per metafieldsSet call.
+// It returns the saved metafields.
+//
+// Each call is atomic: if Shopify rejects one metafield, none of its call is saved.
+// The other calls are still sent, and the user errors of all calls are returned as UserErrors,
+// with the field paths indexed in metafields (e.g. ["metafields", "30", "value"]).
+//
+// Example:
+//
+//	saved, err := shopify.SetMetafields(ctx, shop, token, metafields)
+//	var userErrs shopify.UserErrors
+//	if errors.As(err, &userErrs) {
+//	    // ...
+//	}
+func SetMetafields(ctx context.Context, shop, accessToken string, metafields []Metafield) ([]Metafield, error) {
+	var saved []Metafield
+	var userErrs UserErrors
+
+	for start := 0; start < len(metafields); start += metafieldsSetLimit {
+		chunk := metafields[start:min(start+metafieldsSetLimit, len(metafields))]
+
+		input := make([]map[string]string, len(chunk))
+		for i, m := range chunk {
+			input[i] = map[string]string{
+				"ownerId":   m.OwnerID,
+				"namespace": m.Namespace,
+				"key":       m.Key,
+				"type":      m.Type,
+				"value":     m.Value,
+			}
+		}
+
+		resp, err := Mutate[struct {
+			Set struct {
+				Metafields []struct {
+					Metafield
+					Owner struct {
+						ID string `json:"id"`
+					} `json:"owner"`
+				} `json:"metafields"`
+			} `json:"metafieldsSet"`
+		}](ctx, shop, accessToken, metafieldsSetMutation, map[string]any{"metafields": input})
+
+		var chunkErrs UserErrors
+		if errors.As(err, &chunkErrs) {
+			userErrs = append(userErrs, offsetUserErrors(chunkErrs, start)...)
+			continue
+		}
+		if err != nil {
+			return saved, err
+		}
+
+		for _, m := range resp.Set.Metafields {
+			m.OwnerID = m.Owner.ID
+			saved = append(saved, m.Metafield)
+		}
+	}
+
+	if len(userErrs) > 0 {
+		return saved, userErrs
+	}
+	return saved, nil
+}
+
+// offsetUserErrors adds the offset of the chunk to the index in the field paths
+func offsetUserErrors(errs UserErrors, offset int) UserErrors {
+	for i, err := range errs {
+		if len(err.Field) > 1 && err.Field[0] == "metafields" {
+			if index, convErr := strconv.Atoi(err.Field[1]); convErr == nil {
+				field := append([]string{}, err.Field...)
+				field[1] = strconv.Itoa(index + offset)
+				errs[i].Field = field
+			}
+		}
+	}
+	return errs
+}
+
+// GetMetafield returns a metafield of the owner with the default client, nil if it is not set.
+//
+// Example:
+//
+//	m, err := shopify.GetMetafield(ctx, shop, token, productID, "custom", "sizes")
+//	var sizes []string
+//	if err == nil && m != nil {
+//	    err = m.Decode(&sizes)
+//	}
+func GetMetafield(ctx context.Context, shop, accessToken, ownerID, namespace, key string) (*Metafield, error) {
+	resp, err := Query[struct {
+		Node *struct {
+			Metafield *Metafield `json:"metafield"`
+		} `json:"node"`
+	}](ctx, shop, accessToken, metafieldQuery, map[string]any{"ownerId": ownerID, "namespace": namespace, "key": key})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Node == nil || resp.Node.Metafield == nil {
+		return nil, nil
+	}
+
+	resp.Node.Metafield.OwnerID = ownerID
+	return resp.Node.Metafield, nil
+}
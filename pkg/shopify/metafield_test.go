@@ -0,0 +1,131 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMetafield_Codec(t *testing.T) {
+	date := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		metafieldType string
+		value         any
+		encoded       string
+		decoded       any // Pointer to the zero value to decode into
+	}{
+		{"single_line_text_field", "Blue", "Blue", new(string)},
+		{"number_integer", 42, "42", new(int)},
+		{"number_decimal", 1.5, "1.5", new(float64)},
+		{"boolean", true, "true", new(bool)},
+		{"date", date, "2025-03-01", new(time.Time)},
+		{"date_time", date.Add(12*time.Hour + 30*time.Minute), "2025-03-01T12:30:00Z", new(time.Time)},
+		{"money", Money{Amount: "5.99", CurrencyCode: "USD"}, `{"amount":"5.99","currency_code":"USD"}`, new(Money)},
+		{"json", map[string]int{"a": 1}, `{"a":1}`, new(map[string]int)},
+		{"list.single_line_text_field", []string{"S", "M"}, `["S","M"]`, new([]string)},
+		{"list.number_integer", []int64{1, 2}, `[1,2]`, new([]int64)},
+		{"list.number_decimal", []float64{1.5}, `["1.5"]`, new([]float64)},
+		{"list.date", []time.Time{date}, `["2025-03-01"]`, new([]time.Time)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metafieldType, func(t *testing.T) {
+			m, err := NewMetafield("gid://shopify/Product/1", "custom", "key", tt.metafieldType, tt.value)
+			if err != nil {
+				t.Fatalf("NewMetafield returned error: %v", err)
+			}
+			if m.Value != tt.encoded {
+				t.Errorf("Expected value %s, got %s", tt.encoded, m.Value)
+			}
+
+			if err = m.Decode(tt.decoded); err != nil {
+				t.Fatalf("Decode returned error: %v", err)
+			}
+			want, _ := json.Marshal(tt.value)
+			got, _ := json.Marshal(tt.decoded)
+			if string(want) != string(got) {
+				t.Errorf("Expected decoded %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestMetafield_DateTimeWithoutOffset(t *testing.T) {
+	m := &Metafield{Type: "date_time", Value: "2022-02-02T12:30:00"}
+	var got time.Time
+	if err := m.Decode(&got); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if want := time.Date(2022, 2, 2, 12, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestMetafield_TypeMismatch(t *testing.T) {
+	if _, err := NewMetafield("", "custom", "key", "number_integer", "abc"); !errors.Is(err, ErrMetafieldType) {
+		t.Errorf("Expected ErrMetafieldType, got %v", err)
+	}
+	if _, err := NewMetafield("", "custom", "key", "list.single_line_text_field", "abc"); !errors.Is(err, ErrMetafieldType) {
+		t.Errorf("Expected ErrMetafieldType for a list, got %v", err)
+	}
+
+	m := &Metafield{Type: "boolean", Value: "true"}
+	var n int
+	if err := m.Decode(&n); !errors.Is(err, ErrMetafieldType) {
+		t.Errorf("Expected ErrMetafieldType decoding into int, got %v", err)
+	}
+}
+
+func TestSetMetafields_Chunks(t *testing.T) {
+	var sizes []int
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables struct {
+				Metafields []map[string]string `json:"metafields"`
+			} `json:"variables"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		sizes = append(sizes, len(req.Variables.Metafields))
+
+		if len(sizes) == 2 {
+			w.Write([]byte(`{"data":{"metafieldsSet":{"metafields":null,"userErrors":[
+				{"field":["metafields","1","value"],"message":"Value is invalid","code":"INVALID_VALUE"}]}}}`))
+			return
+		}
+		resp := `{"data":{"metafieldsSet":{"metafields":[`
+		for i := range req.Variables.Metafields {
+			if i > 0 {
+				resp += ","
+			}
+			resp += `{"id":"1","namespace":"custom","key":"k","type":"number_integer","value":"1","owner":{"id":"gid://shopify/Product/1"}}`
+		}
+		w.Write([]byte(resp + `],"userErrors":[]}}}`))
+	})
+	defer closeServer()
+	SetDefaultClient(client)
+	defer SetDefaultClient(NewClient())
+
+	metafields := make([]Metafield, 60)
+	for i := range metafields {
+		metafields[i] = Metafield{OwnerID: "gid://shopify/Product/1", Namespace: "custom", Key: "k", Type: "number_integer", Value: "1"}
+	}
+
+	saved, err := SetMetafields(context.Background(), "abc.myshopify.com", "token", metafields)
+
+	if len(sizes) != 3 || sizes[0] != 25 || sizes[2] != 10 {
+		t.Errorf("Expected chunks of 25, 25, 10, got %v", sizes)
+	}
+	if len(saved) != 35 || saved[0].OwnerID != "gid://shopify/Product/1" {
+		t.Errorf("Expected 35 saved metafields, got %d", len(saved))
+	}
+
+	var userErrs UserErrors
+	if !errors.As(err, &userErrs) || len(userErrs.Field("metafields.26.value")) != 1 {
+		t.Errorf("Expected user error on metafields.26.value, got %v", err)
+	}
+}
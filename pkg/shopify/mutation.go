@@ -0,0 +1,156 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrGraphQL matches every GraphQLErrors (top-level errors field of a response).
+	ErrGraphQL = errors.New("shopify: graphql error")
+	// ErrAccessDenied matches the GraphQLErrors with the ACCESS_DENIED code,
+	// the access token is missing a scope required by the query.
+	ErrAccessDenied = errors.New("shopify: access denied")
+	// ErrThrottled matches the GraphQLErrors with the THROTTLED code, after the client gave up retrying.
+	ErrThrottled = errors.New("shopify: throttled")
+)
+
+// UserError is a userErrors item of a mutation payload.
+type UserError struct {
+	Field   []string `json:"field"`   // Path of the input field, e.g. ["input", "title"]
+	Message string   `json:"message"` // Human-readable message
+	Code    string   `json:"code"`    // e.g. TAKEN, INVALID, BLANK, empty if not selected
+}
+
+// UserErrors is the userErrors field of a mutation payload.
+// Shopify returns them with HTTP 200 and a null result, they are not GraphQLErrors.
+//
+// Example:
+//
+//	var userErrs shopify.UserErrors
+//	if errors.As(err, &userErrs) && userErrs.HasCode("TAKEN") {
+//	    // ...
+//	}
+type UserErrors []UserError
+
+// Error returns the fields and messages of the errors
+func (e UserErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		if len(err.Field) > 0 {
+			messages[i] = strings.Join(err.Field, ".") + ": " + err.Message
+		} else {
+			messages[i] = err.Message
+		}
+	}
+	return "shopify user errors: " + strings.Join(messages, "; ")
+}
+
+// HasCode returns true if one of the errors has the given code.
+func (e UserErrors) HasCode(code string) bool {
+	for _, err := range e {
+		if err.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Field returns the errors of the field path (e.g. "input.title").
+func (e UserErrors) Field(path string) UserErrors {
+	var errs UserErrors
+	for _, err := range e {
+		if strings.Join(err.Field, ".") == path {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Mutate sends a mutation with the default client and returns the data field.
+// Generic type T is the data struct.
+// If there are no variables, you can omit the last argument.
+//
+// Unlike Query, the userErrors of the mutation payloads (userErrors, customerUserErrors, ...)
+// are returned as UserErrors, select them in the mutation to get them checked.
+//
+// Example:
+//
+//	resp, err := shopify.Mutate[struct {
+//	    ProductCreate struct {
+//	        Product struct{ ID string } `json:"product"`
+//	    } `json:"productCreate"`
+//	}](ctx, shop, token, `mutation ($input: ProductInput!) {
+//	    productCreate(input: $input) { product { id } userErrors { field message code } }
+//	}`, map[string]any{"input": input})
+func Mutate[T any](ctx context.Context, shop, accessToken, mutation string, variables ...any) (*T, error) {
+	var vars any
+	if len(variables) > 0 {
+		vars = variables[0]
+	}
+
+	var raw json.RawMessage
+	if err := defaultClient.Do(ctx, shop, accessToken, mutation, vars, &raw); err != nil {
+		return nil, err
+	}
+
+	var data T
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+	}
+
+	userErrs, err := findUserErrors(raw)
+	if err != nil {
+		return &data, err
+	}
+	if len(userErrs) > 0 {
+		return &data, userErrs
+	}
+	return &data, nil
+}
+
+// findUserErrors collects the userErrors of the mutation payloads (top-level fields of data)
+func findUserErrors(data json.RawMessage) (UserErrors, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var payloads map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payloads); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mutation payloads: %w", err)
+	}
+
+	// Sorted for a stable error message
+	names := make([]string, 0, len(payloads))
+	for name := range payloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs UserErrors
+	for _, name := range names {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(payloads[name], &fields) != nil {
+			continue // Not an object payload
+		}
+
+		for key, value := range fields {
+			if key != "userErrors" && !strings.HasSuffix(key, "UserErrors") {
+				continue
+			}
+
+			var userErrs UserErrors
+			if err := json.Unmarshal(value, &userErrs); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %s.%s: %w", name, key, err)
+			}
+			errs = append(errs, userErrs...)
+		}
+	}
+	return errs, nil
+}
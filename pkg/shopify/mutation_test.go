@@ -0,0 +1,78 @@
+package shopify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestMutate_UserErrors(t *testing.T) {
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"productCreate":{"product":null,"userErrors":[
+			{"field":["input","handle"],"message":"Handle has already been taken","code":"TAKEN"},
+			{"field":null,"message":"Something else"}]}}}`))
+	})
+	defer closeServer()
+	SetDefaultClient(client)
+	defer SetDefaultClient(NewClient())
+
+	_, err := Mutate[struct{}](context.Background(), "abc.myshopify.com", "token", `mutation`, map[string]any{"input": 1})
+
+	var userErrs UserErrors
+	if !errors.As(err, &userErrs) {
+		t.Fatalf("Expected UserErrors, got %v", err)
+	}
+	if len(userErrs) != 2 || !userErrs.HasCode("TAKEN") {
+		t.Errorf("Unexpected user errors: %+v", userErrs)
+	}
+	if handle := userErrs.Field("input.handle"); len(handle) != 1 || handle[0].Message != "Handle has already been taken" {
+		t.Errorf("Unexpected input.handle errors: %+v", handle)
+	}
+	if err.Error() != "shopify user errors: input.handle: Handle has already been taken; Something else" {
+		t.Errorf("Unexpected message: %s", err.Error())
+	}
+}
+
+func TestMutate_Success(t *testing.T) {
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"customerCreate":{"customer":{"id":"1"},"customerUserErrors":[]}}}`))
+	})
+	defer closeServer()
+	SetDefaultClient(client)
+	defer SetDefaultClient(NewClient())
+
+	resp, err := Mutate[struct {
+		CustomerCreate struct {
+			Customer struct{ ID string } `json:"customer"`
+		} `json:"customerCreate"`
+	}](context.Background(), "abc.myshopify.com", "token", `mutation`)
+	if err != nil {
+		t.Fatalf("Mutate returned error: %v", err)
+	}
+	if resp.CustomerCreate.Customer.ID != "1" {
+		t.Errorf("Expected customer ID '1', got '%s'", resp.CustomerCreate.Customer.ID)
+	}
+}
+
+func TestGraphQLErrors_Is(t *testing.T) {
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":[{"message":"Access denied for orders field.","extensions":{"code":"ACCESS_DENIED"}}]}`))
+	})
+	defer closeServer()
+	SetDefaultClient(client)
+	defer SetDefaultClient(NewClient())
+
+	_, err := Mutate[struct{}](context.Background(), "abc.myshopify.com", "token", `mutation`)
+
+	if !errors.Is(err, ErrGraphQL) || !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected ErrGraphQL and ErrAccessDenied, got %v", err)
+	}
+	if errors.Is(err, ErrThrottled) {
+		t.Errorf("Expected not ErrThrottled, got %v", err)
+	}
+	var userErrs UserErrors
+	if errors.As(err, &userErrs) {
+		t.Errorf("Expected no UserErrors, got %v", userErrs)
+	}
+}
@@ -0,0 +1,312 @@
+package shopify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tuyendt0112/golib/pkg/https"
+	"github.com/tuyendt0112/golib/pkg/telegram"
+)
+
+// nonceTTL is how long the merchant has to approve the install.
+const nonceTTL = 10 * time.Minute
+
+var (
+	// ErrInvalidShop is returned when the shop is not a valid myshopify.com domain.
+	ErrInvalidShop = errors.New("shopify: invalid shop domain")
+	// ErrInvalidHMAC is returned when the hmac query parameter doesn't match.
+	ErrInvalidHMAC = errors.New("shopify: invalid hmac")
+	// ErrInvalidState is returned when the state nonce is unknown, expired or already used.
+	ErrInvalidState = errors.New("shopify: invalid state")
+	// ErrScopesNotGranted is returned when the merchant didn't grant all requested scopes.
+	ErrScopesNotGranted = errors.New("shopify: requested scopes not granted")
+)
+
+// shopDomainRegexp matches the myshopify.com domain of a shop.
+var shopDomainRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-]*\.myshopify\.com$`)
+
+// ValidShopDomain returns true if shop is a myshopify.com domain (e.g. "abc.myshopify.com").
+// Always check the shop parameter before using it in a URL.
+func ValidShopDomain(shop string) bool {
+	return shopDomainRegexp.MatchString(shop)
+}
+
+// VerifyQueryHMAC checks the hmac parameter of a query sent by Shopify (install, callback,
+// embedded app load) in constant time. The message is the other parameters sorted by key.
+func VerifyQueryHMAC(secret string, query url.Values) bool {
+	expected, err := hex.DecodeString(query.Get("hmac"))
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if k != "hmac" && k != "signature" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	params := make([]string, len(keys))
+	for i, k := range keys {
+		params[i] = k + "=" + strings.Join(query[k], ",")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join(params, "&")))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// OAuthOptions contains configuration for the OAuth install flow.
+type OAuthOptions struct {
+	scopes      []string                                                      // Requested access scopes
+	redirectURI string                                                        // Callback URL, must be allowed in the app settings
+	nonces      NonceStore                                                    // Where the state nonces are kept
+	tokens      TokenStore                                                    // Where the access tokens are saved, nil to not save them
+	onInstall   []func(ctx context.Context, token *AccessToken, newUser bool) // Called after a successful install
+}
+
+// WithScopes returns an option function to set the requested access scopes.
+//
+// Example:
+//
+//	oauth := shopify.NewOAuth(key, secret, shopify.WithScopes("read_products", "write_orders"))
+func WithScopes(scopes ...string) func(option *OAuthOptions) {
+	return func(option *OAuthOptions) {
+		option.scopes = append(option.scopes, scopes...)
+	}
+}
+
+// WithRedirectURI returns an option function to set the callback URL.
+func WithRedirectURI(uri string) func(option *OAuthOptions) {
+	return func(option *OAuthOptions) {
+		option.redirectURI = uri
+	}
+}
+
+// WithNonceStore returns an option function to set where the state nonces are kept.
+// Default: in memory, use NewRedisNonceStore with more than one replica.
+func WithNonceStore(store NonceStore) func(option *OAuthOptions) {
+	return func(option *OAuthOptions) {
+		option.nonces = store
+	}
+}
+
+// WithTokenStore returns an option function to save the access tokens after install.
+func WithTokenStore(store TokenStore) func(option *OAuthOptions) {
+	return func(option *OAuthOptions) {
+		option.tokens = store
+	}
+}
+
+// WithInstallHook returns an option function to add a function called after a successful install.
+// newUser is false when the shop already had a token in the token store (re-install).
+func WithInstallHook(hook func(ctx context.Context, token *AccessToken, newUser bool)) func(option *OAuthOptions) {
+	return func(option *OAuthOptions) {
+		option.onInstall = append(option.onInstall, hook)
+	}
+}
+
+// WithTelegramInstall returns an option function to send a Telegram install message
+// (telegram.SendInstall) after a successful install, the shop is used as the domain.
+//
+// Example:
+//
+//	oauth := shopify.NewOAuth(key, secret,
+//	    shopify.WithTelegramInstall(telegram.WithToken(botToken), telegram.WithChannelID("@installs")),
+//	)
+func WithTelegramInstall(ops ...func(option *telegram.Options)) func(option *OAuthOptions) {
+	return WithInstallHook(func(_ context.Context, token *AccessToken, newUser bool) {
+		telegram.NewTelegram(token.Shop, ops...).SendInstall(newUser)
+	})
+}
+
+// OAuth implements the Shopify install handshake (authorization code grant).
+//
+// HOW it works:
+//  1. AuthorizeURL validates the shop and redirects the merchant with a new state nonce
+//  2. Callback verifies the hmac, shop and state, exchanges the code for an access token,
+//     checks the granted scopes, saves the token and calls the install hooks
+type OAuth struct {
+	apiKey    string
+	apiSecret string
+	options   *OAuthOptions
+	tokenURL  func(shop string) string // Builds the access token URL of the shop
+}
+
+// NewOAuth creates a new OAuth flow for the app's API key and secret.
+//
+// Usage:
+//
+//	oauth := shopify.NewOAuth(os.Getenv("SHOPIFY_API_KEY"), os.Getenv("SHOPIFY_API_SECRET"),
+//	    shopify.WithScopes("read_products"),
+//	    shopify.WithRedirectURI("https://app.example.com/auth/callback"),
+//	    shopify.WithNonceStore(shopify.NewRedisNonceStore(redis.NewClientRedis())),
+//	    shopify.WithTokenStore(tokenStore), // shopify.NewRedisTokenStore
+//	)
+func NewOAuth(apiKey, apiSecret string, ops ...func(option *OAuthOptions)) *OAuth {
+	options := &OAuthOptions{
+		nonces: NewMemoryNonceStore(),
+	}
+
+	for _, op := range ops {
+		op(options)
+	}
+
+	return &OAuth{
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		options:   options,
+		tokenURL: func(shop string) string {
+			return https.MakeShopifyURL(shop, "/admin/oauth/access_token")
+		},
+	}
+}
+
+// AuthorizeURL returns the URL to redirect the merchant to, to approve the install.
+// A new state nonce is saved for the shop.
+func (o *OAuth) AuthorizeURL(ctx context.Context, shop string) (string, error) {
+	if !ValidShopDomain(shop) {
+		return "", ErrInvalidShop
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return "", err
+	}
+
+	if err = o.options.nonces.Save(ctx, shop, nonce, nonceTTL); err != nil {
+		return "", fmt.Errorf("failed to save nonce: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("client_id", o.apiKey)
+	query.Set("scope", strings.Join(o.options.scopes, ","))
+	query.Set("redirect_uri", o.options.redirectURI)
+	query.Set("state", nonce)
+
+	return "https://" + shop + "/admin/oauth/authorize?" + query.Encode(), nil
+}
+
+// accessTokenResponse is the response of the access token endpoint
+type accessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	Scope       string `json:"scope"`
+}
+
+// Callback handles the redirect from Shopify after the merchant approved the install,
+// query is the query of the callback request.
+// Returns ErrScopesNotGranted (wrapped, with the missing scopes) if the merchant didn't grant
+// all requested scopes, the token is not saved in that case.
+func (o *OAuth) Callback(ctx context.Context, query url.Values) (*AccessToken, error) {
+	shop := query.Get("shop")
+	if !ValidShopDomain(shop) {
+		return nil, ErrInvalidShop
+	}
+
+	if !VerifyQueryHMAC(o.apiSecret, query) {
+		return nil, ErrInvalidHMAC
+	}
+
+	ok, err := o.options.nonces.Consume(ctx, shop, query.Get("state"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check nonce: %w", err)
+	}
+	if !ok {
+		return nil, ErrInvalidState
+	}
+
+	resp, err := https.DoJSON[accessTokenResponse](o.tokenURL(shop),
+		https.WithMethod(https.POST),
+		https.WithJSONReq(map[string]string{
+			"client_id":     o.apiKey,
+			"client_secret": o.apiSecret,
+			"code":          query.Get("code"),
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	token := &AccessToken{
+		Shop:        shop,
+		Token:       resp.AccessToken,
+		Scopes:      splitScopes(resp.Scope),
+		InstalledAt: time.Now(),
+	}
+
+	if missing := missingScopes(o.options.scopes, token.Scopes); len(missing) > 0 {
+		return token, fmt.Errorf("%w: %s", ErrScopesNotGranted, strings.Join(missing, ","))
+	}
+
+	newUser := true
+	if o.options.tokens != nil {
+		if previous, err := o.options.tokens.Get(ctx, shop); err == nil && previous != nil {
+			newUser = false
+		}
+		if err = o.options.tokens.Save(ctx, token); err != nil {
+			return nil, fmt.Errorf("failed to save token: %w", err)
+		}
+	}
+
+	for _, hook := range o.options.onInstall {
+		hook(ctx, token, newUser)
+	}
+
+	slog.Info("shopify: app installed", "shop", shop, "newUser", newUser)
+	return token, nil
+}
+
+// newNonce returns a random state nonce
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// splitScopes splits a comma-separated scope list
+func splitScopes(scope string) []string {
+	var scopes []string
+	for _, s := range strings.Split(scope, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// missingScopes returns the requested scopes that are not granted.
+// A write_ scope implies the read_ scope of the same resource.
+func missingScopes(requested, granted []string) []string {
+	grantedSet := map[string]bool{}
+	for _, scope := range granted {
+		grantedSet[scope] = true
+		if resource, ok := strings.CutPrefix(scope, "write_"); ok {
+			grantedSet["read_"+resource] = true
+		}
+		if resource, ok := strings.CutPrefix(scope, "unauthenticated_write_"); ok {
+			grantedSet["unauthenticated_read_"+resource] = true
+		}
+	}
+
+	var missing []string
+	for _, scope := range requested {
+		if !grantedSet[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
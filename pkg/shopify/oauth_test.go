@@ -0,0 +1,169 @@
+package shopify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuyendt0112/golib/pkg/https"
+)
+
+func signQuery(secret string, query url.Values) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(query.Encode())) // Encode sorts by key, the test values need no escaping
+	query.Set("hmac", hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestValidShopDomain(t *testing.T) {
+	tests := map[string]bool{
+		"abc.myshopify.com":          true,
+		"my-shop-1.myshopify.com":    true,
+		"abc.myshopify.com.evil.com": false,
+		"evil.com/abc.myshopify.com": false,
+		"-abc.myshopify.com":         false,
+		"":                           false,
+	}
+
+	for shop, want := range tests {
+		if got := ValidShopDomain(shop); got != want {
+			t.Errorf("ValidShopDomain(%q) = %v, want %v", shop, got, want)
+		}
+	}
+}
+
+func TestVerifyQueryHMAC(t *testing.T) {
+	query := url.Values{"shop": {"abc.myshopify.com"}, "timestamp": {"1337178173"}, "code": {"0907a61c0c8d55e99db179b68161bc00"}}
+	signQuery("secret", query)
+
+	if !VerifyQueryHMAC("secret", query) {
+		t.Error("VerifyQueryHMAC should accept a valid hmac")
+	}
+
+	if VerifyQueryHMAC("other-secret", query) {
+		t.Error("VerifyQueryHMAC should reject a hmac signed with another secret")
+	}
+
+	query.Set("shop", "evil.myshopify.com")
+	if VerifyQueryHMAC("secret", query) {
+		t.Error("VerifyQueryHMAC should reject a modified query")
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	missing := missingScopes([]string{"read_products", "write_orders", "read_customers"}, []string{"write_products", "write_orders"})
+	if len(missing) != 1 || missing[0] != "read_customers" {
+		t.Errorf("Expected [read_customers], got %v", missing)
+	}
+}
+
+// memoryTokenStore is a test implementation of TokenStore
+type memoryTokenStore map[string]*AccessToken
+
+func (m memoryTokenStore) Save(_ context.Context, token *AccessToken) error {
+	m[token.Shop] = token
+	return nil
+}
+
+func (m memoryTokenStore) Get(_ context.Context, shop string) (*AccessToken, error) {
+	return m[shop], nil
+}
+
+func (m memoryTokenStore) Revoke(_ context.Context, shop string) error {
+	if token := m[shop]; token != nil {
+		token.Token = ""
+		token.UninstalledAt = time.Now()
+	}
+	return nil
+}
+
+func TestOAuth_Flow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"shpat_123","scope":"write_products"}`))
+	}))
+	defer server.Close()
+
+	tokens := memoryTokenStore{}
+	var installed []bool
+	oauth := NewOAuth("key", "secret",
+		WithScopes("read_products"),
+		WithRedirectURI("https://app.example.com/callback"),
+		WithTokenStore(tokens),
+		WithInstallHook(func(ctx context.Context, token *AccessToken, newUser bool) {
+			installed = append(installed, newUser)
+		}),
+	)
+	oauth.tokenURL = func(shop string) string { return server.URL }
+
+	ctx := context.Background()
+	if _, err := oauth.AuthorizeURL(ctx, "evil.com"); !errors.Is(err, ErrInvalidShop) {
+		t.Errorf("Expected ErrInvalidShop, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		authorizeURL, err := oauth.AuthorizeURL(ctx, "abc.myshopify.com")
+		if err != nil {
+			t.Fatalf("AuthorizeURL returned error: %v", err)
+		}
+		if !strings.HasPrefix(authorizeURL, "https://abc.myshopify.com/admin/oauth/authorize?") {
+			t.Errorf("Unexpected authorize URL: %s", authorizeURL)
+		}
+
+		u, _ := url.Parse(authorizeURL)
+		query := url.Values{"shop": {"abc.myshopify.com"}, "code": {"code"}, "state": {u.Query().Get("state")}}
+		signQuery("secret", query)
+
+		token, err := oauth.Callback(ctx, query)
+		if err != nil {
+			t.Fatalf("Callback returned error: %v", err)
+		}
+		if token.Token != "shpat_123" || tokens["abc.myshopify.com"] == nil {
+			t.Errorf("Expected saved token, got %+v", token)
+		}
+
+		// The state nonce can't be used twice
+		if _, err = oauth.Callback(ctx, query); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("Expected ErrInvalidState, got %v", err)
+		}
+	}
+
+	if len(installed) != 2 || !installed[0] || installed[1] {
+		t.Errorf("Expected install then re-install, got %v", installed)
+	}
+}
+
+func TestOAuth_TokenURLBaseURL(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`{"access_token":"shpat_123","scope":"read_products"}`))
+	}))
+	defer server.Close()
+
+	https.SetShopifyBaseURL(server.URL)
+	defer https.SetShopifyBaseURL("")
+
+	oauth := NewOAuth("key", "secret", WithScopes("read_products"), WithTokenStore(memoryTokenStore{}))
+	ctx := context.Background()
+	authorizeURL, err := oauth.AuthorizeURL(ctx, "abc.myshopify.com")
+	if err != nil {
+		t.Fatalf("AuthorizeURL returned error: %v", err)
+	}
+	u, _ := url.Parse(authorizeURL)
+	query := url.Values{"shop": {"abc.myshopify.com"}, "code": {"code"}, "state": {u.Query().Get("state")}}
+	signQuery("secret", query)
+
+	if _, err = oauth.Callback(ctx, query); err != nil {
+		t.Fatalf("Callback returned error: %v", err)
+	}
+	if path != "/abc.myshopify.com/admin/oauth/access_token" {
+		t.Errorf("Expected the token exchange behind the base URL, got %q", path)
+	}
+}
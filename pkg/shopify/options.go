@@ -0,0 +1,44 @@
+package shopify
+
+import "github.com/tuyendt0112/golib/pkg/https"
+
+// Options contains configuration for the Shopify GraphQL client.
+type Options struct {
+	throttle    ThrottleStore              // Where the cost budget of each shop is kept
+	maxRetries  int                        // Maximum number of retries of a THROTTLED query
+	httpOptions []func(cfg *https.Options) // Extra options for every request (e.g. https.WithTimeout)
+}
+
+// WithThrottleStore returns an option function to set where the cost budget of each shop is kept.
+// Use NewRedisThrottleStore to share one budget per shop between replicas.
+//
+// Example:
+//
+//	client := shopify.NewClient(shopify.WithThrottleStore(shopify.NewRedisThrottleStore(redis.NewClientRedis())))
+func WithThrottleStore(store ThrottleStore) func(option *Options) {
+	return func(option *Options) {
+		option.throttle = store
+	}
+}
+
+// WithMaxRetries returns an option function to set the maximum number of retries of a THROTTLED query.
+//
+// Example:
+//
+//	client := shopify.NewClient(shopify.WithMaxRetries(10))
+func WithMaxRetries(number int) func(option *Options) {
+	return func(option *Options) {
+		option.maxRetries = number
+	}
+}
+
+// WithHTTPOptions returns an option function to add https options to every request.
+//
+// Example:
+//
+//	client := shopify.NewClient(shopify.WithHTTPOptions(https.WithTimeout(30)))
+func WithHTTPOptions(ops ...func(cfg *https.Options)) func(option *Options) {
+	return func(option *Options) {
+		option.httpOptions = append(option.httpOptions, ops...)
+	}
+}
@@ -0,0 +1,109 @@
+package shopify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ProxyRequest is the context of a request forwarded by the app proxy.
+type ProxyRequest struct {
+	Shop       string // e.g. "abc.myshopify.com"
+	CustomerID string // logged_in_customer_id, empty if no customer is logged in
+	PathPrefix string // path_prefix, e.g. "/apps/my-app"
+}
+
+// VerifyProxySignature checks the signature parameter of an app proxy request in constant time.
+// Unlike VerifyQueryHMAC, the parameters are sorted and concatenated without separator.
+func VerifyProxySignature(secret string, query url.Values) bool {
+	expected, err := hex.DecodeString(query.Get("signature"))
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if k != "signature" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, k := range keys {
+		mac.Write([]byte(k + "=" + strings.Join(query[k], ",")))
+	}
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// proxyContextKey is the context key of the app proxy request
+type proxyContextKey struct{}
+
+// ProxyFromContext returns the app proxy request set by the middleware, nil if there is none.
+func ProxyFromContext(ctx context.Context) *ProxyRequest {
+	proxy, _ := ctx.Value(proxyContextKey{}).(*ProxyRequest)
+	return proxy
+}
+
+// ProxyMiddleware returns a net/http middleware that verifies the app proxy signature
+// and puts the shop and customer into the request context, see ProxyFromContext.
+// Invalid requests get 401.
+//
+// Example:
+//
+//	http.Handle("/proxy/", shopify.ProxyMiddleware(os.Getenv("SHOPIFY_API_SECRET"), proxyHandler))
+func ProxyMiddleware(secret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxy, ok := verifyProxy(secret, r.URL.Query())
+		if !ok {
+			slog.Warn("shopify: invalid app proxy signature", "path", r.URL.Path)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), proxyContextKey{}, proxy)))
+	})
+}
+
+// FastHTTPProxyMiddleware is the fasthttp version of ProxyMiddleware,
+// ProxyFromContext works with the *fasthttp.RequestCtx.
+func FastHTTPProxyMiddleware(secret string, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		query, err := url.ParseQuery(string(ctx.URI().QueryString()))
+		if err != nil {
+			ctx.SetStatusCode(fasthttp.StatusBadRequest)
+			return
+		}
+
+		proxy, ok := verifyProxy(secret, query)
+		if !ok {
+			slog.Warn("shopify: invalid app proxy signature", "path", string(ctx.Path()))
+			ctx.SetStatusCode(fasthttp.StatusUnauthorized)
+			return
+		}
+
+		ctx.SetUserValue(proxyContextKey{}, proxy)
+		next(ctx)
+	}
+}
+
+// verifyProxy verifies the signature and returns the proxy request
+func verifyProxy(secret string, query url.Values) (*ProxyRequest, bool) {
+	if !VerifyProxySignature(secret, query) || !ValidShopDomain(query.Get("shop")) {
+		return nil, false
+	}
+
+	return &ProxyRequest{
+		Shop:       query.Get("shop"),
+		CustomerID: query.Get("logged_in_customer_id"),
+		PathPrefix: query.Get("path_prefix"),
+	}, true
+}
@@ -0,0 +1,49 @@
+package shopify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestVerifyProxySignature(t *testing.T) {
+	query := url.Values{
+		"shop":                  {"abc.myshopify.com"},
+		"logged_in_customer_id": {"42"},
+		"path_prefix":           {"/apps/test"},
+		"timestamp":             {"1700000000"},
+		"extra":                 {"1", "2"},
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("extra=1,2logged_in_customer_id=42path_prefix=/apps/testshop=abc.myshopify.comtimestamp=1700000000"))
+	query.Set("signature", hex.EncodeToString(mac.Sum(nil)))
+
+	if !VerifyProxySignature("secret", query) {
+		t.Error("Expected valid signature")
+	}
+	if VerifyProxySignature("other", query) {
+		t.Error("Expected invalid signature with another secret")
+	}
+
+	handler := ProxyMiddleware("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxy := ProxyFromContext(r.Context())
+		w.Write([]byte(proxy.Shop + " " + proxy.CustomerID))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/proxy?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "abc.myshopify.com 42" {
+		t.Errorf("Expected 200 with shop and customer, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	query.Set("logged_in_customer_id", "43")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/proxy?"+query.Encode(), nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a tampered query, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,222 @@
+package shopify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// defaultSessionClockSkew is the default tolerance on exp and nbf.
+const defaultSessionClockSkew = 10 * time.Second
+
+var (
+	// ErrInvalidSessionToken is returned when a session token is malformed, badly signed
+	// or issued for another app or shop.
+	ErrInvalidSessionToken = errors.New("shopify: invalid session token")
+	// ErrSessionTokenExpired is returned (with ErrInvalidSessionToken) when exp is in the past.
+	// App Bridge fetches a new token every minute, the client should retry with a fresh one.
+	ErrSessionTokenExpired = errors.New("shopify: session token expired")
+)
+
+// SessionClaims are the claims of an App Bridge session token.
+type SessionClaims struct {
+	Issuer    string `json:"iss"`  // e.g. "https://abc.myshopify.com/admin"
+	Dest      string `json:"dest"` // e.g. "https://abc.myshopify.com"
+	Audience  string `json:"aud"`  // API key of the app
+	Subject   string `json:"sub"`  // ID of the staff user
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+	IssuedAt  int64  `json:"iat"`
+	ID        string `json:"jti"`
+	SessionID string `json:"sid"`
+}
+
+// Shop returns the myshopify.com domain of the shop (dest without scheme).
+func (c *SessionClaims) Shop() string {
+	return strings.TrimPrefix(c.Dest, "https://")
+}
+
+// UserID returns the ID of the staff user, empty for tokens not tied to a user.
+func (c *SessionClaims) UserID() string {
+	return c.Subject
+}
+
+// SessionOptions contains configuration for the session token verifier.
+type SessionOptions struct {
+	clockSkew time.Duration    // Tolerance on exp and nbf
+	now       func() time.Time // Current time, overridden in tests
+}
+
+// WithSessionClockSkew returns an option function to set the tolerance on exp and nbf.
+// Default: 10s.
+func WithSessionClockSkew(skew time.Duration) func(option *SessionOptions) {
+	return func(option *SessionOptions) {
+		option.clockSkew = skew
+	}
+}
+
+// SessionVerifier verifies the session tokens sent by the embedded app frontend.
+//
+// HOW it works:
+//  1. Checks the HS256 signature with the app secret
+//  2. Checks exp and nbf, with the clock-skew tolerance
+//  3. Checks that aud is the API key and that dest is a shop domain matching iss
+type SessionVerifier struct {
+	apiKey    string
+	apiSecret []byte
+	options   *SessionOptions
+}
+
+// NewSessionVerifier creates a new session token verifier for the app's API key and secret.
+//
+// Usage:
+//
+//	verifier := shopify.NewSessionVerifier(os.Getenv("SHOPIFY_API_KEY"), os.Getenv("SHOPIFY_API_SECRET"))
+//	http.Handle("/api/", verifier.Middleware(apiHandler))
+func NewSessionVerifier(apiKey, apiSecret string, ops ...func(option *SessionOptions)) *SessionVerifier {
+	options := &SessionOptions{
+		clockSkew: defaultSessionClockSkew,
+		now:       time.Now,
+	}
+
+	for _, op := range ops {
+		op(options)
+	}
+
+	return &SessionVerifier{
+		apiKey:    apiKey,
+		apiSecret: []byte(apiSecret),
+		options:   options,
+	}
+}
+
+// Verify checks the session token and returns its claims.
+// Returns ErrInvalidSessionToken (wrapped, with the reason) if the token is not valid.
+func (v *SessionVerifier) Verify(token string) (*SessionClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidSessionToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return nil, fmt.Errorf("%w: unsupported header", ErrInvalidSessionToken)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidSessionToken)
+	}
+	mac := hmac.New(sha256.New, v.apiSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidSessionToken)
+	}
+
+	claims := &SessionClaims{}
+	if err = decodeSegment(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidSessionToken)
+	}
+
+	now := v.options.now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.options.clockSkew)) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSessionToken, ErrSessionTokenExpired)
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-v.options.clockSkew)) {
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalidSessionToken)
+	}
+	if claims.Audience != v.apiKey {
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidSessionToken)
+	}
+	if !strings.HasPrefix(claims.Dest, "https://") || !ValidShopDomain(claims.Shop()) {
+		return nil, fmt.Errorf("%w: invalid dest", ErrInvalidSessionToken)
+	}
+	if claims.Issuer != "" && !strings.HasPrefix(claims.Issuer, claims.Dest+"/") {
+		return nil, fmt.Errorf("%w: iss doesn't match dest", ErrInvalidSessionToken)
+	}
+
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// sessionContextKey is the context key of the session claims
+type sessionContextKey struct{}
+
+// SessionFromContext returns the session claims set by the middleware, nil if there are none.
+//
+// Example:
+//
+//	session := shopify.SessionFromContext(r.Context())
+//	token, err := tokens.Get(r.Context(), session.Shop())
+func SessionFromContext(ctx context.Context) *SessionClaims {
+	claims, _ := ctx.Value(sessionContextKey{}).(*SessionClaims)
+	return claims
+}
+
+// Middleware returns a net/http middleware that verifies the session token of the request
+// (Authorization: Bearer, or the id_token query parameter on document loads) and puts the
+// claims into the request context, see SessionFromContext.
+// Invalid requests get 401 with X-Shopify-Retry-Invalid-Session-Request, so App Bridge retries
+// them with a new token.
+func (v *SessionVerifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := v.verifyRequest(r.Header.Get("Authorization"), r.URL.Query().Get("id_token"))
+		if err != nil {
+			slog.Debug("shopify: rejected session token", "path", r.URL.Path, "err", err)
+			w.Header().Set("X-Shopify-Retry-Invalid-Session-Request", "1")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, claims)))
+	})
+}
+
+// FastHTTPMiddleware is the fasthttp version of Middleware,
+// SessionFromContext works with the *fasthttp.RequestCtx.
+func (v *SessionVerifier) FastHTTPMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		claims, err := v.verifyRequest(string(ctx.Request.Header.Peek("Authorization")), string(ctx.QueryArgs().Peek("id_token")))
+		if err != nil {
+			slog.Debug("shopify: rejected session token", "path", string(ctx.Path()), "err", err)
+			ctx.Response.Header.Set("X-Shopify-Retry-Invalid-Session-Request", "1")
+			ctx.SetStatusCode(fasthttp.StatusUnauthorized)
+			return
+		}
+
+		ctx.SetUserValue(sessionContextKey{}, claims)
+		next(ctx)
+	}
+}
+
+// verifyRequest verifies the bearer token, or the id_token query parameter
+func (v *SessionVerifier) verifyRequest(authorization, idToken string) (*SessionClaims, error) {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		token = idToken
+	}
+	if token == "" {
+		return nil, fmt.Errorf("%w: missing", ErrInvalidSessionToken)
+	}
+	return v.Verify(token)
+}
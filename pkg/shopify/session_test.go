@@ -0,0 +1,121 @@
+package shopify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func signSessionToken(secret string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func sessionClaims(now time.Time) map[string]any {
+	return map[string]any{
+		"iss":  "https://abc.myshopify.com/admin",
+		"dest": "https://abc.myshopify.com",
+		"aud":  "key",
+		"sub":  "42",
+		"exp":  now.Add(time.Minute).Unix(),
+		"nbf":  now.Unix(),
+		"iat":  now.Unix(),
+	}
+}
+
+func TestSessionVerifier_Verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	verifier := NewSessionVerifier("key", "secret", WithSessionClockSkew(5*time.Second))
+	verifier.options.now = func() time.Time { return now }
+
+	claims, err := verifier.Verify(signSessionToken("secret", sessionClaims(now)))
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if claims.Shop() != "abc.myshopify.com" || claims.UserID() != "42" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		change func(claims map[string]any)
+	}{
+		{"bad signature", "other", func(claims map[string]any) {}},
+		{"expired", "secret", func(claims map[string]any) { claims["exp"] = now.Add(-6 * time.Second).Unix() }},
+		{"not valid yet", "secret", func(claims map[string]any) { claims["nbf"] = now.Add(6 * time.Second).Unix() }},
+		{"wrong audience", "secret", func(claims map[string]any) { claims["aud"] = "other" }},
+		{"invalid dest", "secret", func(claims map[string]any) { claims["dest"] = "https://evil.com" }},
+		{"iss mismatch", "secret", func(claims map[string]any) { claims["iss"] = "https://xyz.myshopify.com/admin" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := sessionClaims(now)
+			tt.change(claims)
+			if _, err := verifier.Verify(signSessionToken(tt.secret, claims)); !errors.Is(err, ErrInvalidSessionToken) {
+				t.Errorf("Expected ErrInvalidSessionToken, got %v", err)
+			}
+		})
+	}
+
+	// Within the clock skew
+	skewed := sessionClaims(now)
+	skewed["exp"] = now.Add(-4 * time.Second).Unix()
+	if _, err = verifier.Verify(signSessionToken("secret", skewed)); err != nil {
+		t.Errorf("Expected token within clock skew to be valid, got %v", err)
+	}
+
+	skewed["exp"] = now.Add(-time.Minute).Unix()
+	if _, err = verifier.Verify(signSessionToken("secret", skewed)); !errors.Is(err, ErrSessionTokenExpired) {
+		t.Errorf("Expected ErrSessionTokenExpired, got %v", err)
+	}
+}
+
+func TestSessionVerifier_Middleware(t *testing.T) {
+	verifier := NewSessionVerifier("key", "secret")
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(SessionFromContext(r.Context()).Shop()))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/products", nil)
+	req.Header.Set("Authorization", "Bearer "+signSessionToken("secret", sessionClaims(time.Now())))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "abc.myshopify.com" {
+		t.Errorf("Expected 200 with shop, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/products", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("X-Shopify-Retry-Invalid-Session-Request") != "1" {
+		t.Errorf("Expected 401 with retry header, got %d", rec.Code)
+	}
+}
+
+func TestSessionVerifier_FastHTTPMiddleware(t *testing.T) {
+	verifier := NewSessionVerifier("key", "secret")
+	handler := verifier.FastHTTPMiddleware(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString(SessionFromContext(ctx).UserID())
+	})
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/app?id_token=" + signSessionToken("secret", sessionClaims(time.Now())))
+	handler(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || string(ctx.Response.Body()) != "42" {
+		t.Errorf("Expected 200 with user ID, got %d %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+}
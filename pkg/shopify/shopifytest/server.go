@@ -0,0 +1,476 @@
+// Package shopifytest provides a stand-in for the Shopify Admin API, for integration tests.
+//
+// It emulates a subset of the API:
+//   - GraphQL products and orders connections with cursor pagination (first, after)
+//   - The cost extensions (throttleStatus) and THROTTLED responses of the GraphQL API
+//   - The products.json and orders.json REST endpoints, with X-Shopify-Shop-Api-Call-Limit and 429
+//   - Signed webhooks sent to the app
+//
+// Usage:
+//
+//	server := shopifytest.NewServer(shopifytest.WithProducts(shopifytest.Product{ID: "gid://shopify/Product/1", Title: "Shirt"}))
+//	defer server.Close()
+//
+//	// https.MakeShopifyGraphqlURL and https.MakeShopifyRestURL now point to the server
+//	for product, err := range shopify.Paginate[Product](ctx, "abc.myshopify.com", "token", query) {
+//	    // ...
+//	}
+//
+// The server overrides the Shopify base URL of the process (https.SetShopifyBaseURL),
+// only one Server can run at a time: don't use it from parallel tests (t.Parallel).
+package shopifytest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tuyendt0112/golib/pkg/https"
+)
+
+const (
+	// DefaultSecret is the app secret used to sign the webhooks, see WithSecret.
+	DefaultSecret = "shopifytest-secret"
+
+	// defaultPageSize is the page size of a connection without first.
+	defaultPageSize = 50
+)
+
+var (
+	// connectionRegexp finds the connection field of a query and its arguments
+	connectionRegexp = regexp.MustCompile(`\b(products|orders)\s*(?:\(([^)]*)\))?\s*\{`)
+	// firstRegexp and afterRegexp find the pagination arguments of a connection
+	firstRegexp = regexp.MustCompile(`\bfirst\s*:\s*(\$\w+|\d+)`)
+	afterRegexp = regexp.MustCompile(`\bafter\s*:\s*(\$\w+|"[^"]*"|null)`)
+)
+
+// Product is a product of the stand-in shop.
+type Product struct {
+	ID     string `json:"id"` // e.g. "gid://shopify/Product/1"
+	Title  string `json:"title"`
+	Handle string `json:"handle"`
+}
+
+// Order is an order of the stand-in shop.
+type Order struct {
+	ID         string `json:"id"` // e.g. "gid://shopify/Order/1"
+	Name       string `json:"name"`
+	TotalPrice string `json:"totalPrice"`
+}
+
+// Options contains configuration for the stand-in server.
+type Options struct {
+	secret      string    // App secret of the webhooks
+	accessToken string    // Expected access token, empty to accept any token
+	products    []Product // Products of every shop
+	orders      []Order   // Orders of every shop
+	maxCost     float64   // GraphQL bucket size
+	restoreRate float64   // GraphQL points restored per second
+	restSize    float64   // REST bucket size
+}
+
+// WithSecret returns an option function to set the app secret used to sign the webhooks.
+func WithSecret(secret string) func(option *Options) {
+	return func(option *Options) {
+		option.secret = secret
+	}
+}
+
+// WithAccessToken returns an option function to reject the requests without this access token (401).
+// By default any non-empty token is accepted.
+func WithAccessToken(token string) func(option *Options) {
+	return func(option *Options) {
+		option.accessToken = token
+	}
+}
+
+// WithProducts returns an option function to add products to every shop.
+func WithProducts(products ...Product) func(option *Options) {
+	return func(option *Options) {
+		option.products = append(option.products, products...)
+	}
+}
+
+// WithOrders returns an option function to add orders to every shop.
+func WithOrders(orders ...Order) func(option *Options) {
+	return func(option *Options) {
+		option.orders = append(option.orders, orders...)
+	}
+}
+
+// WithGraphQLThrottle returns an option function to set the GraphQL cost bucket of each shop.
+// Default: 1000 points, 50 restored per second (standard plan).
+func WithGraphQLThrottle(maxCost, restoreRate float64) func(option *Options) {
+	return func(option *Options) {
+		option.maxCost = maxCost
+		option.restoreRate = restoreRate
+	}
+}
+
+// WithRESTBucket returns an option function to set the REST bucket size of each shop,
+// it leaks size/20 calls per second like Shopify. Default: 40.
+func WithRESTBucket(size float64) func(option *Options) {
+	return func(option *Options) {
+		option.restSize = size
+	}
+}
+
+// bucket is a leaky bucket, used for both GraphQL points (available) and REST calls (used)
+type bucket struct {
+	level     float64
+	updatedAt time.Time
+}
+
+// Server is a stand-in for the Shopify Admin API, based on httptest.
+// Every shop has the same products and orders, but its own GraphQL and REST buckets.
+type Server struct {
+	*httptest.Server
+
+	options      *Options
+	mu           sync.Mutex
+	graphql      map[string]*bucket // Available points by shop
+	rest         map[string]*bucket // Used calls by shop
+	throttleNext int                // Number of next GraphQL queries answered THROTTLED
+	graphqlCalls atomic.Int64
+	restCalls    atomic.Int64
+}
+
+// NewServer starts a new stand-in server and points https.MakeShopifyGraphqlURL and
+// https.MakeShopifyRestURL to it, until Close.
+// The base URL is process-wide, close a Server before starting another one.
+func NewServer(ops ...func(option *Options)) *Server {
+	options := &Options{
+		secret:      DefaultSecret,
+		maxCost:     1000,
+		restoreRate: 50,
+		restSize:    40,
+	}
+	for _, op := range ops {
+		op(options)
+	}
+
+	s := &Server{
+		options: options,
+		graphql: map[string]*bucket{},
+		rest:    map[string]*bucket{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	https.SetShopifyBaseURL(s.URL)
+	return s
+}
+
+// Close shuts down the server and removes the base URL override.
+func (s *Server) Close() {
+	https.SetShopifyBaseURL("")
+	s.Server.Close()
+}
+
+// ThrottleNext makes the next n GraphQL queries fail with THROTTLED, whatever the budget.
+func (s *Server) ThrottleNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttleNext = n
+}
+
+// GraphQLCalls returns the number of GraphQL requests received.
+func (s *Server) GraphQLCalls() int {
+	return int(s.graphqlCalls.Load())
+}
+
+// RESTCalls returns the number of REST requests received.
+func (s *Server) RESTCalls() int {
+	return int(s.restCalls.Load())
+}
+
+// SendWebhook sends a webhook signed with the app secret to url and returns the status code of the app.
+//
+// Example:
+//
+//	status, err := server.SendWebhook(app.URL+"/webhooks", "orders/create", "abc.myshopify.com", order)
+func (s *Server) SendWebhook(url, topic, shop string, payload any) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		return 0, err
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.options.secret))
+	mac.Write(body)
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Shopify-Topic", topic)
+	req.Header.Set("X-Shopify-Shop-Domain", shop)
+	req.Header.Set("X-Shopify-Webhook-Id", hex.EncodeToString(id))
+	req.Header.Set("X-Shopify-API-Version", "unstable")
+	req.Header.Set("X-Shopify-Hmac-Sha256", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// serveHTTP routes /<shop>/admin/api/<version>/<resource>.json
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 5)
+	if len(parts) != 5 || parts[1] != "admin" || parts[2] != "api" {
+		writeJSON(w, http.StatusNotFound, map[string]any{"errors": "Not Found"})
+		return
+	}
+	shop, resource := parts[0], strings.TrimSuffix(parts[4], ".json")
+
+	token := r.Header.Get("X-Shopify-Access-Token")
+	if token == "" || (s.options.accessToken != "" && token != s.options.accessToken) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"errors": "[API] Invalid API key or access token (unrecognized login or wrong password)",
+		})
+		return
+	}
+
+	if resource == "graphql" {
+		s.graphqlCalls.Add(1)
+		s.serveGraphQL(w, r, shop)
+		return
+	}
+
+	s.restCalls.Add(1)
+	s.serveREST(w, r, shop, resource)
+}
+
+// serveGraphQL answers a products or orders connection query
+func (s *Server) serveGraphQL(w http.ResponseWriter, r *http.Request, shop string) {
+	var req struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"errors": "Invalid JSON"})
+		return
+	}
+
+	match := connectionRegexp.FindStringSubmatch(req.Query)
+	if match == nil {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"errors": []map[string]any{{"message": "shopifytest: unsupported query"}},
+		})
+		return
+	}
+
+	first := defaultPageSize
+	if arg := firstRegexp.FindStringSubmatch(match[2]); arg != nil {
+		if n, ok := resolveArg(arg[1], req.Variables).(float64); ok {
+			first = int(n)
+		}
+	}
+	if first < 0 {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"errors": []map[string]any{{"message": "The first argument must be a non-negative integer"}},
+		})
+		return
+	}
+	after := -1
+	if arg := afterRegexp.FindStringSubmatch(match[2]); arg != nil {
+		if cursor, ok := resolveArg(arg[1], req.Variables).(string); ok {
+			after = decodeCursor(cursor)
+		}
+	}
+
+	var items []any
+	if match[1] == "products" {
+		for _, p := range s.options.products {
+			items = append(items, p)
+		}
+	} else {
+		for _, o := range s.options.orders {
+			items = append(items, o)
+		}
+	}
+
+	// An invalid cursor starts from the beginning, a cursor past the end returns an empty page
+	start := min(max(after+1, 0), len(items))
+	end := start + min(first, len(items)-start)
+	page := items[start:end]
+
+	// Like Shopify: 2 points for the connection and one per requested node
+	requested := float64(2 + first)
+	actual := float64(2 + len(page))
+
+	status, throttled := s.spend(shop, requested, actual)
+	cost := map[string]any{
+		"requestedQueryCost": requested,
+		"throttleStatus":     status,
+	}
+	if throttled {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"errors": []map[string]any{{
+				"message":    "Throttled",
+				"extensions": map[string]any{"code": "THROTTLED"},
+			}},
+			"extensions": map[string]any{"cost": cost},
+		})
+		return
+	}
+	cost["actualQueryCost"] = actual
+
+	edges := make([]map[string]any, len(page))
+	for i, item := range page {
+		edges[i] = map[string]any{"cursor": encodeCursor(start + i), "node": item}
+	}
+	pageInfo := map[string]any{
+		"hasNextPage":     end < len(items),
+		"hasPreviousPage": start > 0,
+		"startCursor":     nil,
+		"endCursor":       nil,
+	}
+	if len(page) > 0 {
+		pageInfo["startCursor"] = encodeCursor(start)
+		pageInfo["endCursor"] = encodeCursor(end - 1)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data": map[string]any{
+			match[1]: map[string]any{"edges": edges, "nodes": page, "pageInfo": pageInfo},
+		},
+		"extensions": map[string]any{"cost": cost},
+	})
+}
+
+// spend takes the actual cost from the GraphQL bucket of the shop if it has the requested cost,
+// it returns the throttleStatus after the query and true if the query is throttled
+func (s *Server) spend(shop string, requested, actual float64) (map[string]any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.graphql[shop]
+	if !ok {
+		b = &bucket{level: s.options.maxCost, updatedAt: now}
+		s.graphql[shop] = b
+	}
+	b.level = math.Min(s.options.maxCost, b.level+s.options.restoreRate*now.Sub(b.updatedAt).Seconds())
+	b.updatedAt = now
+
+	throttled := s.throttleNext > 0 || requested > b.level
+	if s.throttleNext > 0 {
+		s.throttleNext--
+	}
+	if !throttled {
+		b.level -= actual
+	}
+
+	return map[string]any{
+		"maximumAvailable":   s.options.maxCost,
+		"currentlyAvailable": math.Floor(b.level),
+		"restoreRate":        s.options.restoreRate,
+	}, throttled
+}
+
+// serveREST answers the products.json and orders.json endpoints
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request, shop, resource string) {
+	used, ok := s.call(shop)
+	w.Header().Set("X-Shopify-Shop-Api-Call-Limit", fmt.Sprintf("%d/%d", int(math.Ceil(used)), int(s.options.restSize)))
+	if !ok {
+		w.Header().Set("Retry-After", "1.0")
+		writeJSON(w, http.StatusTooManyRequests, map[string]any{"errors": "Exceeded 2 calls per second for api client. Reduce request rates to resume uninterrupted service."})
+		return
+	}
+
+	limit := defaultPageSize
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+
+	switch resource {
+	case "products":
+		writeJSON(w, http.StatusOK, map[string]any{"products": s.options.products[:min(limit, len(s.options.products))]})
+	case "orders":
+		writeJSON(w, http.StatusOK, map[string]any{"orders": s.options.orders[:min(limit, len(s.options.orders))]})
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]any{"errors": "Not Found"})
+	}
+}
+
+// call adds a call to the REST bucket of the shop, it returns the calls in the bucket
+// and false if the bucket is full
+func (s *Server) call(shop string) (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.rest[shop]
+	if !ok {
+		b = &bucket{updatedAt: now}
+		s.rest[shop] = b
+	}
+	b.level = math.Max(0, b.level-s.options.restSize/20*now.Sub(b.updatedAt).Seconds())
+	b.updatedAt = now
+
+	if b.level+1 > s.options.restSize {
+		return b.level, false
+	}
+	b.level++
+	return b.level, true
+}
+
+// resolveArg returns the value of a literal or $variable argument
+func resolveArg(arg string, variables map[string]any) any {
+	switch {
+	case strings.HasPrefix(arg, "$"):
+		return variables[arg[1:]]
+	case strings.HasPrefix(arg, `"`):
+		return strings.Trim(arg, `"`)
+	case arg == "null":
+		return nil
+	}
+	n, _ := strconv.ParseFloat(arg, 64)
+	return n
+}
+
+// encodeCursor returns the opaque cursor of an index
+func encodeCursor(index int) string {
+	return base64.StdEncoding.EncodeToString([]byte("cursor:" + strconv.Itoa(index)))
+}
+
+// decodeCursor returns the index of a cursor, -1 if it is invalid
+func decodeCursor(cursor string) int {
+	b, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return -1
+	}
+	index, err := strconv.Atoi(strings.TrimPrefix(string(b), "cursor:"))
+	if err != nil {
+		return -1
+	}
+	return index
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,137 @@
+package shopifytest_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tuyendt0112/golib/pkg/https"
+	"github.com/tuyendt0112/golib/pkg/shopify"
+	"github.com/tuyendt0112/golib/pkg/shopify/shopifytest"
+)
+
+func newServer() *shopifytest.Server {
+	var products []shopifytest.Product
+	for i := 1; i <= 5; i++ {
+		products = append(products, shopifytest.Product{ID: fmt.Sprintf("gid://shopify/Product/%d", i), Title: fmt.Sprintf("Product %d", i)})
+	}
+	return shopifytest.NewServer(shopifytest.WithProducts(products...), shopifytest.WithAccessToken("token"))
+}
+
+func TestServer_Paginate(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	type product struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+
+	server.ThrottleNext(1)
+	var titles []string
+	for p, err := range shopify.Paginate[product](context.Background(), "abc.myshopify.com", "token", `query ($cursor: String) {
+		products(first: 2, after: $cursor) { nodes { id title } pageInfo { hasNextPage endCursor } }
+	}`) {
+		if err != nil {
+			t.Fatalf("Paginate returned error: %v", err)
+		}
+		titles = append(titles, p.Title)
+	}
+
+	if len(titles) != 5 || titles[4] != "Product 5" {
+		t.Errorf("Expected 5 products, got %v", titles)
+	}
+	// 3 pages and 1 retry of the throttled query
+	if server.GraphQLCalls() != 4 {
+		t.Errorf("Expected 4 GraphQL calls, got %d", server.GraphQLCalls())
+	}
+}
+
+func TestServer_REST(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	var resp struct {
+		Products []shopifytest.Product `json:"products"`
+	}
+	headers := https.M{}
+	err := https.Do(https.MakeShopifyRestURL("abc.myshopify.com", "products"),
+		https.WithShopifyAccessToken("token"),
+		https.WithQuery("limit", "3"),
+		https.WithJSONRespTo(&resp),
+		https.WithHeaderRespTo(headers),
+	)
+	if err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	if len(resp.Products) != 3 {
+		t.Errorf("Expected 3 products, got %d", len(resp.Products))
+	}
+	if limit := headers["X-Shopify-Shop-Api-Call-Limit"]; limit != "1/40" {
+		t.Errorf("Expected call limit 1/40, got %v", limit)
+	}
+
+	err = https.Do(https.MakeShopifyRestURL("abc.myshopify.com", "products"), https.WithShopifyAccessToken("wrong"))
+	if e, ok := err.(*https.ErrorStatusNotOK); !ok || e.Code != 401 {
+		t.Errorf("Expected 401, got %v", err)
+	}
+}
+
+func TestServer_SendWebhook(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	var topic string
+	receiver := shopify.NewWebhookReceiver(shopifytest.DefaultSecret)
+	receiver.Handle("orders/create", func(ctx context.Context, webhook *shopify.Webhook) error {
+		topic = webhook.Topic
+		return nil
+	})
+	app := httptest.NewServer(receiver)
+	defer app.Close()
+
+	status, err := server.SendWebhook(app.URL, "orders/create", "abc.myshopify.com", shopifytest.Order{ID: "gid://shopify/Order/1"})
+	if err != nil || status != 200 || topic != "orders/create" {
+		t.Errorf("Expected handled webhook, got %d %q %v", status, topic, err)
+	}
+}
+
+func TestServer_PaginateArgs(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	query := func(first int, after string) (nodes int, errors int) {
+		var resp struct {
+			Data struct {
+				Products struct {
+					Nodes []shopifytest.Product `json:"nodes"`
+				} `json:"products"`
+			} `json:"data"`
+			Errors []struct{ Message string } `json:"errors"`
+		}
+		err := https.Do(https.MakeShopifyGraphqlURL("abc.myshopify.com"),
+			https.WithShopifyAccessToken("token"),
+			https.WithGraphQLReq(`query ($first: Int, $after: String) {
+				products(first: $first, after: $after) { nodes { id } }
+			}`, map[string]any{"first": first, "after": after}),
+			https.WithJSONRespTo(&resp),
+		)
+		if err != nil {
+			t.Fatalf("Do returned error: %v", err)
+		}
+		return len(resp.Data.Products.Nodes), len(resp.Errors)
+	}
+
+	if _, errors := query(-1, ""); errors != 1 {
+		t.Errorf("Expected an error for a negative first, got %d", errors)
+	}
+	// "cursor:10", past the end
+	if nodes, errors := query(2, "Y3Vyc29yOjEw"); nodes != 0 || errors != 0 {
+		t.Errorf("Expected an empty page, got %d nodes and %d errors", nodes, errors)
+	}
+	// "cursor:-5"
+	if nodes, errors := query(2, "Y3Vyc29yOi01"); nodes != 2 || errors != 0 {
+		t.Errorf("Expected the first page, got %d nodes and %d errors", nodes, errors)
+	}
+}
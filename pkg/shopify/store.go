@@ -0,0 +1,129 @@
+package shopify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// AccessToken is the offline access token of a shop, obtained at install.
+type AccessToken struct {
+	Shop          string    `json:"shop"`          // e.g. "abc.myshopify.com"
+	Token         string    `json:"token"`         // Value for https.WithShopifyAccessToken, empty once revoked
+	Scopes        []string  `json:"scopes"`        // Granted access scopes
+	InstalledAt   time.Time `json:"installedAt"`   // Last install
+	UninstalledAt time.Time `json:"uninstalledAt"` // Last uninstall, zero while installed
+}
+
+// Active returns true if the shop has the app installed and a token to call the API with.
+func (t *AccessToken) Active() bool {
+	return t.Token != "" && t.UninstalledAt.IsZero()
+}
+
+// TokenStore keeps the access token of each shop.
+type TokenStore interface {
+	// Save saves the token of the shop, replacing the previous one.
+	Save(ctx context.Context, token *AccessToken) error
+
+	// Get returns the token of the shop, nil if the shop has no token.
+	// A revoked entry is returned with an empty token and UninstalledAt set, see AccessToken.Active.
+	Get(ctx context.Context, shop string) (*AccessToken, error)
+
+	// Revoke deletes the token of the shop and records the uninstall time,
+	// the scopes and install time are kept.
+	Revoke(ctx context.Context, shop string) error
+}
+
+// NonceStore keeps the OAuth state nonces between the authorize redirect and the callback.
+type NonceStore interface {
+	// Save saves the nonce of the shop for ttl.
+	Save(ctx context.Context, shop, nonce string, ttl time.Duration) error
+
+	// Consume deletes the nonce of the shop and returns true if it matched.
+	// A nonce can only be consumed once.
+	Consume(ctx context.Context, shop, nonce string) (bool, error)
+}
+
+// memoryNonceStore keeps the nonces in the process memory.
+type memoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]memoryNonce
+}
+
+// memoryNonce is a nonce with its expiration
+type memoryNonce struct {
+	value     string
+	expiresAt time.Time
+}
+
+// NewMemoryNonceStore creates a NonceStore that keeps the nonces in the process memory.
+// Use NewRedisNonceStore when the app runs with more than one replica.
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{nonces: map[string]memoryNonce{}}
+}
+
+// Save saves the nonce of the shop for ttl
+func (m *memoryNonceStore) Save(_ context.Context, shop, nonce string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, v := range m.nonces {
+		if now.After(v.expiresAt) {
+			delete(m.nonces, k)
+		}
+	}
+
+	m.nonces[shop] = memoryNonce{value: nonce, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// Consume deletes the nonce of the shop and returns true if it matched
+func (m *memoryNonceStore) Consume(_ context.Context, shop, nonce string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	saved, ok := m.nonces[shop]
+	delete(m.nonces, shop)
+	return ok && saved.value == nonce && time.Now().Before(saved.expiresAt), nil
+}
+
+// redisNonceStore keeps the nonces in Redis.
+type redisNonceStore struct {
+	client *goredis.Client
+	prefix string
+}
+
+// NewRedisNonceStore creates a NonceStore that keeps the nonces in Redis.
+// Keys are prefixed with APP_NAME.
+//
+// Example:
+//
+//	store := shopify.NewRedisNonceStore(redis.NewClientRedis())
+func NewRedisNonceStore(client *goredis.Client) NonceStore {
+	return &redisNonceStore{
+		client: client,
+		prefix: os.Getenv("APP_NAME") + ":shopify:nonce:",
+	}
+}
+
+// Save saves the nonce of the shop for ttl
+func (r *redisNonceStore) Save(ctx context.Context, shop, nonce string, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+shop, nonce, ttl).Err()
+}
+
+// Consume deletes the nonce of the shop and returns true if it matched
+func (r *redisNonceStore) Consume(ctx context.Context, shop, nonce string) (bool, error) {
+	saved, err := r.client.GetDel(ctx, r.prefix+shop).Result()
+	if errors.Is(err, goredis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return saved == nonce, nil
+}
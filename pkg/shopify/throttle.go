@@ -0,0 +1,152 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// ThrottleStatus is the cost budget of a shop returned in extensions.cost.throttleStatus.
+type ThrottleStatus struct {
+	MaximumAvailable   float64 `json:"maximumAvailable"`   // Size of the bucket
+	CurrentlyAvailable float64 `json:"currentlyAvailable"` // Points available when the query finished
+	RestoreRate        float64 `json:"restoreRate"`        // Points restored per second
+}
+
+// Cost is the cost of a query returned in extensions.cost.
+type Cost struct {
+	RequestedQueryCost float64        `json:"requestedQueryCost"`
+	ActualQueryCost    float64        `json:"actualQueryCost"`
+	ThrottleStatus     ThrottleStatus `json:"throttleStatus"`
+}
+
+// ThrottleState is the last known cost budget of a shop.
+type ThrottleState struct {
+	ThrottleStatus
+	UpdatedAt time.Time `json:"updatedAt"` // When the status was returned by Shopify
+}
+
+// Available returns the points available at the given time,
+// including the points restored since the status was returned.
+func (s *ThrottleState) Available(now time.Time) float64 {
+	available := s.CurrentlyAvailable + s.RestoreRate*now.Sub(s.UpdatedAt).Seconds()
+	if s.MaximumAvailable > 0 && available > s.MaximumAvailable {
+		return s.MaximumAvailable
+	}
+	return available
+}
+
+// Delay returns how long to wait at the given time before cost points are available.
+func (s *ThrottleState) Delay(cost float64, now time.Time) time.Duration {
+	missing := cost - s.Available(now)
+	if missing <= 0 || s.RestoreRate <= 0 {
+		return 0
+	}
+	return time.Duration(missing / s.RestoreRate * float64(time.Second))
+}
+
+// restoredIn returns how long it takes to restore the whole bucket,
+// after that the state doesn't matter anymore.
+func (s *ThrottleState) restoredIn() time.Duration {
+	if s.RestoreRate <= 0 {
+		return time.Minute
+	}
+	return time.Duration((s.MaximumAvailable-s.CurrentlyAvailable)/s.RestoreRate*float64(time.Second)) + time.Second
+}
+
+// ThrottleStore keeps the cost budget of each shop.
+//
+// WHY interface?
+//   - The in-memory store is enough for a single process
+//   - The Redis store shares one budget per shop between replicas
+type ThrottleStore interface {
+	// Get returns the last known state of the shop, nil if unknown.
+	Get(ctx context.Context, shop string) (*ThrottleState, error)
+
+	// Set saves the state of the shop.
+	Set(ctx context.Context, shop string, state *ThrottleState) error
+}
+
+// memoryThrottleStore keeps the states in the process memory.
+type memoryThrottleStore struct {
+	mu     sync.Mutex
+	states map[string]ThrottleState
+}
+
+// NewMemoryThrottleStore creates a ThrottleStore that keeps the states in the process memory.
+// This is the default store of the client.
+func NewMemoryThrottleStore() ThrottleStore {
+	return &memoryThrottleStore{states: map[string]ThrottleState{}}
+}
+
+// Get returns the last known state of the shop, nil if unknown
+func (m *memoryThrottleStore) Get(_ context.Context, shop string) (*ThrottleState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.states[shop]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// Set saves the state of the shop
+func (m *memoryThrottleStore) Set(_ context.Context, shop string, state *ThrottleState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.states[shop] = *state
+	return nil
+}
+
+// redisThrottleStore keeps the states in Redis, shared between replicas.
+type redisThrottleStore struct {
+	client *goredis.Client
+	prefix string
+}
+
+// NewRedisThrottleStore creates a ThrottleStore that keeps the states in Redis,
+// so all replicas of the app share one budget per shop.
+// Keys are prefixed with APP_NAME, because each app has its own budget.
+//
+// Example:
+//
+//	store := shopify.NewRedisThrottleStore(redis.NewClientRedis())
+func NewRedisThrottleStore(client *goredis.Client) ThrottleStore {
+	return &redisThrottleStore{
+		client: client,
+		prefix: os.Getenv("APP_NAME") + ":shopify:throttle:",
+	}
+}
+
+// Get returns the last known state of the shop, nil if unknown
+func (r *redisThrottleStore) Get(ctx context.Context, shop string) (*ThrottleState, error) {
+	b, err := r.client.Get(ctx, r.prefix+shop).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &ThrottleState{}
+	if err = json.Unmarshal(b, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Set saves the state of the shop until the bucket is fully restored
+func (r *redisThrottleStore) Set(ctx context.Context, shop string, state *ThrottleState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, r.prefix+shop, b, state.restoredIn()).Err()
+}
@@ -0,0 +1,48 @@
+package shopify
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestThrottleState_Delay(t *testing.T) {
+	now := time.Now()
+	state := &ThrottleState{
+		ThrottleStatus: ThrottleStatus{MaximumAvailable: 1000, CurrentlyAvailable: 100, RestoreRate: 50},
+		UpdatedAt:      now.Add(-time.Second),
+	}
+
+	if available := state.Available(now); available != 150 {
+		t.Errorf("Expected 150 available points, got %v", available)
+	}
+
+	if delay := state.Delay(100, now); delay != 0 {
+		t.Errorf("Expected no delay, got %v", delay)
+	}
+
+	if delay := state.Delay(250, now); delay != 2*time.Second {
+		t.Errorf("Expected 2s delay, got %v", delay)
+	}
+
+	if available := state.Available(now.Add(time.Hour)); available != 1000 {
+		t.Errorf("Available should not exceed the maximum, got %v", available)
+	}
+}
+
+func TestMemoryThrottleStore(t *testing.T) {
+	store := NewMemoryThrottleStore()
+	ctx := context.Background()
+
+	state, err := store.Get(ctx, "abc.myshopify.com")
+	if err != nil || state != nil {
+		t.Errorf("Expected nil state for unknown shop, got %v, %v", state, err)
+	}
+
+	_ = store.Set(ctx, "abc.myshopify.com", &ThrottleState{ThrottleStatus: ThrottleStatus{CurrentlyAvailable: 42}})
+
+	state, err = store.Get(ctx, "abc.myshopify.com")
+	if err != nil || state == nil || state.CurrentlyAvailable != 42 {
+		t.Errorf("Expected saved state, got %v, %v", state, err)
+	}
+}
@@ -0,0 +1,372 @@
+package shopify
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// ErrNoTokenKey is returned when no encryption key is configured for the token store.
+var ErrNoTokenKey = errors.New("shopify: no token encryption key, set SHOPIFY_TOKEN_KEYS")
+
+// errTokenNotEncrypted is returned when decrypting a plaintext token, saved before encryption.
+var errTokenNotEncrypted = errors.New("shopify: token is not encrypted")
+
+// plaintextVersion is the key version of a plaintext token, below every key so that it is encrypted when read.
+const plaintextVersion = -1
+
+// tokenCompareAndSet replaces the entry of a shop only if it is unchanged since it was read.
+// KEYS[1] = entry, ARGV[1] = entry as read, ARGV[2] = new entry
+var tokenCompareAndSet = goredis.NewScript(`
+if redis.call('get', KEYS[1]) == ARGV[1] then
+  return redis.call('set', KEYS[1], ARGV[2])
+end
+return false
+`)
+
+// TokenStoreOptions contains configuration for the encrypted token store.
+type TokenStoreOptions struct {
+	keys map[int][]byte // AES keys by version, the highest version encrypts
+}
+
+// WithTokenKey returns an option function to add an AES-256 key (32 bytes) with its version.
+// The key with the highest version encrypts, the others only decrypt.
+// When set, SHOPIFY_TOKEN_KEYS is ignored.
+func WithTokenKey(version int, key []byte) func(option *TokenStoreOptions) {
+	return func(option *TokenStoreOptions) {
+		if option.keys == nil {
+			option.keys = map[int][]byte{}
+		}
+		option.keys[version] = key
+	}
+}
+
+// tokenCipher encrypts the access tokens with AES-GCM.
+// The ciphertext is "v<version>:<base64(nonce|sealed)>", the shop is the additional data,
+// so a ciphertext can't be copied to another shop.
+type tokenCipher struct {
+	current int
+	aeads   map[int]cipher.AEAD
+}
+
+// newTokenCipher creates a cipher from the keys by version
+func newTokenCipher(keys map[int][]byte) (*tokenCipher, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoTokenKey
+	}
+
+	c := &tokenCipher{current: -1, aeads: map[int]cipher.AEAD{}}
+	for version, key := range keys {
+		if version < 0 {
+			return nil, fmt.Errorf("shopify: invalid token key version %d", version)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("shopify: token key v%d must be 32 bytes, got %d", version, len(key))
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if c.aeads[version], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+		if version > c.current {
+			c.current = version
+		}
+	}
+	return c, nil
+}
+
+// parseTokenKeys parses SHOPIFY_TOKEN_KEYS: "version:base64key" separated by commas
+// (e.g. "2:bmV3...,1:b2xk...")
+func parseTokenKeys(value string) (map[int][]byte, error) {
+	keys := map[int][]byte{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		version, encoded, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("shopify: invalid token key %q, expected version:base64key", item)
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return nil, fmt.Errorf("shopify: invalid token key version %q", version)
+		}
+		if keys[v], err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("shopify: invalid token key v%d: %w", v, err)
+		}
+	}
+	return keys, nil
+}
+
+// encrypt encrypts the token of the shop with the current key
+func (c *tokenCipher) encrypt(shop, token string) (string, error) {
+	aead := c.aeads[c.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(token)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(token), []byte(shop))
+	return "v" + strconv.Itoa(c.current) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt decrypts the token of the shop and returns the version of the key it was encrypted with
+func (c *tokenCipher) decrypt(shop, ciphertext string) (string, int, error) {
+	version, encoded, ok := strings.Cut(ciphertext, ":")
+	if !ok || !strings.HasPrefix(version, "v") {
+		return "", 0, errTokenNotEncrypted
+	}
+	v, err := strconv.Atoi(version[1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("shopify: invalid token key version %q", version)
+	}
+	aead, ok := c.aeads[v]
+	if !ok {
+		return "", v, fmt.Errorf("shopify: unknown token key v%d", v)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", v, errors.New("shopify: malformed encrypted token")
+	}
+	token, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(shop))
+	if err != nil {
+		return "", v, fmt.Errorf("shopify: failed to decrypt token: %w", err)
+	}
+	return string(token), v, nil
+}
+
+// RedisTokenStore is a TokenStore that keeps the tokens in Redis, encrypted with AES-GCM.
+//
+// WHY encrypt?
+//   - An offline token gives full API access to a shop until the app is uninstalled
+//   - A Redis dump or replica must not leak the tokens of every shop
+//
+// HOW key rotation works:
+//  1. Add the new key with a higher version: SHOPIFY_TOKEN_KEYS="2:<new>,1:<old>"
+//  2. New tokens are encrypted with v2, v1 tokens are re-encrypted when read
+//  3. Call Rotate to re-encrypt the tokens that are not read, then remove the old key
+//
+// The plaintext tokens saved before encryption are read as is, and encrypted like the old keys.
+type RedisTokenStore struct {
+	client *goredis.Client
+	prefix string
+	cipher *tokenCipher
+}
+
+// NewRedisTokenStore creates a TokenStore that keeps the tokens in Redis, encrypted with the keys
+// of SHOPIFY_TOKEN_KEYS ("version:base64key" separated by commas, 32-byte keys) or WithTokenKey.
+// Keys are prefixed with APP_NAME.
+//
+// Example:
+//
+//	// openssl rand -base64 32
+//	store, err := shopify.NewRedisTokenStore(redis.NewClientRedis())
+func NewRedisTokenStore(client *goredis.Client, ops ...func(option *TokenStoreOptions)) (*RedisTokenStore, error) {
+	options := &TokenStoreOptions{}
+	for _, op := range ops {
+		op(options)
+	}
+
+	if options.keys == nil {
+		keys, err := parseTokenKeys(os.Getenv("SHOPIFY_TOKEN_KEYS"))
+		if err != nil {
+			return nil, err
+		}
+		options.keys = keys
+	}
+
+	c, err := newTokenCipher(options.keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisTokenStore{
+		client: client,
+		prefix: os.Getenv("APP_NAME") + ":shopify:token:",
+		cipher: c,
+	}, nil
+}
+
+// Save saves the token of the shop, replacing the previous one.
+// InstalledAt is set to now if it is zero.
+func (r *RedisTokenStore) Save(ctx context.Context, token *AccessToken) error {
+	stored := *token
+	if stored.InstalledAt.IsZero() {
+		stored.InstalledAt = time.Now()
+	}
+	return r.set(ctx, &stored)
+}
+
+// Get returns the token of the shop, nil if the shop has no token.
+// Tokens encrypted with an old key, or not encrypted, are re-encrypted with the current one.
+func (r *RedisTokenStore) Get(ctx context.Context, shop string) (*AccessToken, error) {
+	token, version, raw, err := r.get(ctx, shop)
+	if err != nil || token == nil {
+		return token, err
+	}
+
+	if token.Token != "" && version != r.cipher.current {
+		if _, err = r.reencrypt(ctx, token, raw); err != nil {
+			// The token is still readable with the old key
+			slog.Error("shopify: failed to re-encrypt token", "shop", shop, "err", err)
+		}
+	}
+	return token, nil
+}
+
+// Revoke deletes the token of the shop and records the uninstall time,
+// the token is not decrypted so that a shop can be revoked after its key was removed.
+func (r *RedisTokenStore) Revoke(ctx context.Context, shop string) error {
+	token, _, err := r.read(ctx, shop)
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		token = &AccessToken{Shop: shop}
+	}
+
+	token.Token = ""
+	token.UninstalledAt = time.Now()
+	return r.write(ctx, token)
+}
+
+// Rotate re-encrypts the tokens that are not encrypted with the current key,
+// it returns the number of re-encrypted tokens.
+func (r *RedisTokenStore) Rotate(ctx context.Context) (int, error) {
+	rotated := 0
+	iter := r.client.Scan(ctx, 0, r.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		shop := strings.TrimPrefix(iter.Val(), r.prefix)
+		token, version, raw, err := r.get(ctx, shop)
+		if err != nil {
+			return rotated, err
+		}
+		if token == nil || token.Token == "" || version == r.cipher.current {
+			continue
+		}
+
+		ok, err := r.reencrypt(ctx, token, raw)
+		if err != nil {
+			return rotated, err
+		}
+		if ok {
+			rotated++
+		}
+	}
+	return rotated, iter.Err()
+}
+
+// get reads and decrypts the token of the shop, with the version of its key and the entry as stored.
+// A plaintext token has the version plaintextVersion.
+func (r *RedisTokenStore) get(ctx context.Context, shop string) (*AccessToken, int, []byte, error) {
+	token, raw, err := r.read(ctx, shop)
+	if err != nil || token == nil || token.Token == "" {
+		return token, r.cipher.current, raw, err
+	}
+
+	plaintext, version, err := r.cipher.decrypt(shop, token.Token)
+	if errors.Is(err, errTokenNotEncrypted) {
+		return token, plaintextVersion, raw, nil
+	}
+	if err != nil {
+		return nil, version, nil, err
+	}
+	token.Token = plaintext
+	return token, version, raw, nil
+}
+
+// reencrypt encrypts the token with the current key and replaces the entry if it is still raw.
+// It returns false if the entry changed since it was read (e.g. revoked by the uninstall webhook),
+// the entry is then kept, a re-encrypt must never restore a revoked token.
+func (r *RedisTokenStore) reencrypt(ctx context.Context, token *AccessToken, raw []byte) (bool, error) {
+	b, err := r.encode(token)
+	if err != nil {
+		return false, err
+	}
+
+	err = tokenCompareAndSet.Run(ctx, r.client, []string{r.prefix + token.Shop}, raw, b).Err()
+	if errors.Is(err, goredis.Nil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// set encrypts and writes the token
+func (r *RedisTokenStore) set(ctx context.Context, token *AccessToken) error {
+	b, err := r.encode(token)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, r.prefix+token.Shop, b, 0).Err()
+}
+
+// encode encrypts the token and returns the entry to store
+func (r *RedisTokenStore) encode(token *AccessToken) ([]byte, error) {
+	stored := *token
+	if stored.Token != "" {
+		var err error
+		if stored.Token, err = r.cipher.encrypt(token.Shop, token.Token); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(&stored)
+}
+
+// read reads the entry of the shop as stored, with the token encrypted, and its raw value
+func (r *RedisTokenStore) read(ctx context.Context, shop string) (*AccessToken, []byte, error) {
+	b, err := r.client.Get(ctx, r.prefix+shop).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token := &AccessToken{}
+	if err = json.Unmarshal(b, token); err != nil {
+		return nil, nil, err
+	}
+	return token, b, nil
+}
+
+// write writes the entry of the shop as is
+func (r *RedisTokenStore) write(ctx context.Context, token *AccessToken) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, r.prefix+token.Shop, b, 0).Err()
+}
+
+// RevokeTokenHandler returns a webhook handler that revokes the token of the shop,
+// register it for TopicAppUninstalled.
+//
+// Example:
+//
+//	receiver.Handle(shopify.TopicAppUninstalled, shopify.RevokeTokenHandler(store))
+func RevokeTokenHandler(store TokenStore) WebhookHandler {
+	return func(ctx context.Context, webhook *Webhook) error {
+		if err := store.Revoke(ctx, webhook.Shop); err != nil {
+			return fmt.Errorf("failed to revoke token of %s: %w", webhook.Shop, err)
+		}
+		slog.Info("shopify: app uninstalled", "shop", webhook.Shop)
+		return nil
+	}
+}
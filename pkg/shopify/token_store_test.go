@@ -0,0 +1,152 @@
+package shopify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func TestTokenCipher_Rotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	old, err := newTokenCipher(map[int][]byte{1: oldKey})
+	if err != nil {
+		t.Fatalf("newTokenCipher returned error: %v", err)
+	}
+	ciphertext, err := old.encrypt("abc.myshopify.com", "shpat_123")
+	if err != nil {
+		t.Fatalf("encrypt returned error: %v", err)
+	}
+	if !strings.HasPrefix(ciphertext, "v1:") || strings.Contains(ciphertext, "shpat_123") {
+		t.Errorf("Unexpected ciphertext: %s", ciphertext)
+	}
+
+	rotated, _ := newTokenCipher(map[int][]byte{1: oldKey, 2: newKey})
+	token, version, err := rotated.decrypt("abc.myshopify.com", ciphertext)
+	if err != nil || token != "shpat_123" || version != 1 {
+		t.Errorf("Expected shpat_123 from v1, got %q v%d %v", token, version, err)
+	}
+
+	ciphertext, _ = rotated.encrypt("abc.myshopify.com", "shpat_123")
+	if !strings.HasPrefix(ciphertext, "v2:") {
+		t.Errorf("Expected v2 ciphertext, got %s", ciphertext)
+	}
+	if _, _, err = old.decrypt("abc.myshopify.com", ciphertext); err == nil {
+		t.Error("Expected error decrypting v2 with only v1")
+	}
+
+	// The ciphertext is bound to the shop
+	if _, _, err = rotated.decrypt("xyz.myshopify.com", ciphertext); err == nil {
+		t.Error("Expected error decrypting the token of another shop")
+	}
+}
+
+func TestNewTokenCipher_InvalidKeys(t *testing.T) {
+	if _, err := newTokenCipher(nil); !errors.Is(err, ErrNoTokenKey) {
+		t.Errorf("Expected ErrNoTokenKey, got %v", err)
+	}
+	if _, err := newTokenCipher(map[int][]byte{1: []byte("short")}); err == nil {
+		t.Error("Expected error for a short key")
+	}
+}
+
+func TestParseTokenKeys(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	keys, err := parseTokenKeys("2:" + key + ", 1:" + key)
+	if err != nil || len(keys) != 2 || len(keys[2]) != 32 {
+		t.Errorf("Expected 2 keys, got %v %v", keys, err)
+	}
+
+	if _, err = parseTokenKeys(key); err == nil {
+		t.Error("Expected error for a key without version")
+	}
+}
+
+func TestRevokeTokenHandler(t *testing.T) {
+	tokens := memoryTokenStore{"abc.myshopify.com": {Shop: "abc.myshopify.com", Token: "shpat_123"}}
+	receiver := NewWebhookReceiver("secret")
+	receiver.Handle(TopicAppUninstalled, RevokeTokenHandler(tokens))
+
+	err := receiver.Dispatch(context.Background(), &Webhook{Topic: TopicAppUninstalled, Shop: "abc.myshopify.com"})
+	if err != nil {
+		t.Fatalf("Dispatch returned error: %v", err)
+	}
+
+	token := tokens["abc.myshopify.com"]
+	if token.Active() || token.Token != "" || token.UninstalledAt.IsZero() {
+		t.Errorf("Expected revoked token, got %+v", token)
+	}
+}
+
+// newTestTokenStore returns a RedisTokenStore on an in-memory Redis
+func newTestTokenStore(t *testing.T, keys map[int][]byte) (*RedisTokenStore, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	var ops []func(option *TokenStoreOptions)
+	for version, key := range keys {
+		ops = append(ops, WithTokenKey(version, key))
+	}
+	store, err := NewRedisTokenStore(client, ops...)
+	if err != nil {
+		t.Fatalf("NewRedisTokenStore returned error: %v", err)
+	}
+	return store, server
+}
+
+func TestRedisTokenStore_Plaintext(t *testing.T) {
+	store, server := newTestTokenStore(t, map[int][]byte{1: bytes.Repeat([]byte{1}, 32)})
+
+	// Saved by the plaintext store
+	server.Set(store.prefix+"abc.myshopify.com", `{"shop":"abc.myshopify.com","token":"shpat_123","scopes":["read_products"]}`)
+
+	token, err := store.Get(context.Background(), "abc.myshopify.com")
+	if err != nil || token == nil || token.Token != "shpat_123" {
+		t.Fatalf("Expected the plaintext token, got %+v %v", token, err)
+	}
+
+	stored, _ := server.Get(store.prefix + "abc.myshopify.com")
+	if strings.Contains(stored, "shpat_123") || !strings.Contains(stored, `"token":"v1:`) {
+		t.Errorf("Expected the token to be encrypted on read, got %s", stored)
+	}
+	if token, err = store.Get(context.Background(), "abc.myshopify.com"); err != nil || token.Token != "shpat_123" {
+		t.Errorf("Expected the encrypted token, got %+v %v", token, err)
+	}
+}
+
+func TestRedisTokenStore_ReencryptAfterRevoke(t *testing.T) {
+	ctx := context.Background()
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	old, _ := newTestTokenStore(t, map[int][]byte{1: oldKey})
+	if err := old.Save(ctx, &AccessToken{Shop: "abc.myshopify.com", Token: "shpat_123"}); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	store, _ := newTestTokenStore(t, map[int][]byte{1: oldKey, 2: bytes.Repeat([]byte{2}, 32)})
+	store.client = old.client
+
+	// Get reads the v1 token, then the uninstall webhook revokes it before the re-encrypt
+	token, _, raw, err := store.get(ctx, "abc.myshopify.com")
+	if err != nil {
+		t.Fatalf("get returned error: %v", err)
+	}
+	if err = store.Revoke(ctx, "abc.myshopify.com"); err != nil {
+		t.Fatalf("Revoke returned error: %v", err)
+	}
+	if ok, err := store.reencrypt(ctx, token, raw); ok || err != nil {
+		t.Errorf("Expected the re-encrypt to be skipped, got %v %v", ok, err)
+	}
+
+	if token, _ = store.Get(ctx, "abc.myshopify.com"); token == nil || token.Active() {
+		t.Errorf("Expected the token to stay revoked, got %+v", token)
+	}
+}
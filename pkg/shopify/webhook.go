@@ -0,0 +1,281 @@
+package shopify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/tuyendt0112/golib/pkg/queue"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// TopicAppUninstalled is sent when a shop uninstalls the app.
+	TopicAppUninstalled = "app/uninstalled"
+
+	// maxWebhookBodySize is the maximum size of a webhook body read by the receiver.
+	maxWebhookBodySize = 10 << 20
+	// defaultWebhookDedupTTL is how long a webhook ID is remembered, Shopify retries for 48 hours.
+	defaultWebhookDedupTTL = 48 * time.Hour
+)
+
+// Webhook is a webhook delivery from Shopify.
+type Webhook struct {
+	Topic      string          `json:"topic"`      // X-Shopify-Topic (e.g. "orders/create")
+	Shop       string          `json:"shop"`       // X-Shopify-Shop-Domain (e.g. "abc.myshopify.com")
+	WebhookID  string          `json:"webhookId"`  // X-Shopify-Webhook-Id, unique per delivery
+	APIVersion string          `json:"apiVersion"` // X-Shopify-API-Version
+	Body       json.RawMessage `json:"body"`       // Raw JSON payload
+}
+
+// WebhookHandler handles a webhook, returning an error makes Shopify retry the delivery.
+type WebhookHandler func(ctx context.Context, webhook *Webhook) error
+
+// WebhookDeduper drops the webhooks that were already received.
+// Shopify may deliver the same webhook more than once.
+type WebhookDeduper interface {
+	// Seen marks the webhook as received and returns true if it already was.
+	Seen(ctx context.Context, webhookID string) (bool, error)
+
+	// Forget removes the mark, so that a retry of a failed webhook is handled again.
+	Forget(ctx context.Context, webhookID string) error
+}
+
+// WebhookOptions contains configuration for the webhook receiver.
+type WebhookOptions struct {
+	deduper   WebhookDeduper // Drops duplicate deliveries, nil to handle every delivery
+	queueName string         // Queue to hand the webhooks off to, empty to handle them in the request
+}
+
+// WithWebhookDeduper returns an option function to drop duplicate deliveries by X-Shopify-Webhook-Id.
+//
+// Example:
+//
+//	receiver := shopify.NewWebhookReceiver(secret,
+//	    shopify.WithWebhookDeduper(shopify.NewRedisWebhookDeduper(redis.NewClientRedis(), 0)),
+//	)
+func WithWebhookDeduper(deduper WebhookDeduper) func(option *WebhookOptions) {
+	return func(option *WebhookOptions) {
+		option.deduper = deduper
+	}
+}
+
+// WithWebhookQueue returns an option function to hand the verified webhooks off to a queue,
+// so the endpoint replies within Shopify's 5s limit. Use Worker to handle them.
+//
+// Example:
+//
+//	receiver := shopify.NewWebhookReceiver(secret, shopify.WithWebhookQueue("shopify-webhooks"))
+//	go receiver.Worker().RunWithContext(receiver.Dispatch)
+func WithWebhookQueue(queueName string) func(option *WebhookOptions) {
+	return func(option *WebhookOptions) {
+		option.queueName = queueName
+	}
+}
+
+// WebhookReceiver verifies the webhooks sent by Shopify and routes them by topic.
+// It can be used as a net/http handler (ServeHTTP) or a fasthttp handler (HandleFastHTTP).
+//
+// HOW it works:
+//  1. Checks X-Shopify-Hmac-Sha256 against the app secret (401 if invalid)
+//  2. Drops duplicates by X-Shopify-Webhook-Id (if a deduper is set)
+//  3. Calls the handler of X-Shopify-Topic, or dispatches the webhook to the queue
+type WebhookReceiver struct {
+	secret   []byte
+	handlers map[string]WebhookHandler
+	options  *WebhookOptions
+}
+
+// NewWebhookReceiver creates a new webhook receiver for the app secret.
+//
+// Usage:
+//
+//	receiver := shopify.NewWebhookReceiver(os.Getenv("SHOPIFY_API_SECRET"))
+//	shopify.OnWebhook(receiver, "orders/create", func(ctx context.Context, webhook *shopify.Webhook, order *Order) error {
+//	    return saveOrder(webhook.Shop, order)
+//	})
+//	http.Handle("/webhooks", receiver)
+func NewWebhookReceiver(secret string, ops ...func(option *WebhookOptions)) *WebhookReceiver {
+	options := &WebhookOptions{}
+	for _, op := range ops {
+		op(options)
+	}
+
+	return &WebhookReceiver{
+		secret:   []byte(secret),
+		handlers: map[string]WebhookHandler{},
+		options:  options,
+	}
+}
+
+// Handle registers the handler of a topic, replacing the previous one.
+func (r *WebhookReceiver) Handle(topic string, handler WebhookHandler) {
+	r.handlers[topic] = handler
+}
+
+// OnWebhook registers a typed handler of a topic.
+// Generic type T is the payload struct, decoded from the webhook body.
+func OnWebhook[T any](r *WebhookReceiver, topic string, handler func(ctx context.Context, webhook *Webhook, payload *T) error) {
+	r.Handle(topic, func(ctx context.Context, webhook *Webhook) error {
+		payload := new(T)
+		if err := json.Unmarshal(webhook.Body, payload); err != nil {
+			return fmt.Errorf("failed to unmarshal %s webhook: %w", webhook.Topic, err)
+		}
+		return handler(ctx, webhook, payload)
+	})
+}
+
+// VerifyWebhook checks the X-Shopify-Hmac-Sha256 header of a webhook body in constant time.
+func VerifyWebhook(secret string, body []byte, hmacHeader string) bool {
+	return verifyWebhook([]byte(secret), body, hmacHeader)
+}
+
+// verifyWebhook checks the base64 HMAC-SHA256 of the body
+func verifyWebhook(secret, body []byte, hmacHeader string) bool {
+	expected, err := base64.StdEncoding.DecodeString(hmacHeader)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// ServeHTTP implements http.Handler.
+func (r *WebhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookBodySize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(r.receive(req.Context(), body, req.Header.Get))
+}
+
+// HandleFastHTTP is a fasthttp.RequestHandler.
+func (r *WebhookReceiver) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsPost() {
+		ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx.SetStatusCode(r.receive(ctx, ctx.PostBody(), func(key string) string {
+		return string(ctx.Request.Header.Peek(key))
+	}))
+}
+
+// receive verifies and handles a webhook, it returns the HTTP status code of the response
+func (r *WebhookReceiver) receive(ctx context.Context, body []byte, header func(key string) string) int {
+	if !verifyWebhook(r.secret, body, header("X-Shopify-Hmac-Sha256")) {
+		slog.Warn("shopify: invalid webhook HMAC", "topic", header("X-Shopify-Topic"), "shop", header("X-Shopify-Shop-Domain"))
+		return http.StatusUnauthorized
+	}
+
+	webhook := &Webhook{
+		Topic:      header("X-Shopify-Topic"),
+		Shop:       header("X-Shopify-Shop-Domain"),
+		WebhookID:  header("X-Shopify-Webhook-Id"),
+		APIVersion: header("X-Shopify-API-Version"),
+		Body:       append(json.RawMessage{}, body...),
+	}
+
+	if r.options.deduper != nil && webhook.WebhookID != "" {
+		seen, err := r.options.deduper.Seen(ctx, webhook.WebhookID)
+		if err != nil {
+			// Handle the webhook anyway, a duplicate is better than a lost webhook
+			slog.Error("shopify: failed to check webhook ID", "id", webhook.WebhookID, "err", err)
+		} else if seen {
+			slog.Debug("shopify: duplicate webhook", "topic", webhook.Topic, "id", webhook.WebhookID)
+			return http.StatusOK
+		}
+	}
+
+	var err error
+	if r.options.queueName != "" {
+		q := queue.NewQueue[Webhook](r.options.queueName)
+		q.WithData(webhook)
+		err = q.Dispatch()
+	} else {
+		err = r.Dispatch(ctx, webhook)
+	}
+
+	if err != nil {
+		slog.Error("shopify: failed to handle webhook", "topic", webhook.Topic, "shop", webhook.Shop, "err", err)
+		if r.options.deduper != nil && webhook.WebhookID != "" {
+			_ = r.options.deduper.Forget(ctx, webhook.WebhookID)
+		}
+		return http.StatusInternalServerError
+	}
+
+	return http.StatusOK
+}
+
+// Dispatch calls the handler of the webhook's topic.
+// Webhooks without handler are ignored.
+func (r *WebhookReceiver) Dispatch(ctx context.Context, webhook *Webhook) error {
+	handler, ok := r.handlers[webhook.Topic]
+	if !ok {
+		slog.Warn("shopify: no handler for webhook", "topic", webhook.Topic, "shop", webhook.Shop)
+		return nil
+	}
+	return handler(ctx, webhook)
+}
+
+// Worker returns a worker for the queue set by WithWebhookQueue,
+// run it with Dispatch as the job handler.
+//
+// Example:
+//
+//	worker := receiver.Worker(queue.WithMaxFails(5))
+//	go worker.RunWithContext(receiver.Dispatch)
+func (r *WebhookReceiver) Worker(ops ...func(options *queue.Options)) *queue.Worker[Webhook] {
+	return queue.NewWorker[Webhook](r.options.queueName, ops...)
+}
+
+// redisWebhookDeduper remembers the webhook IDs in Redis.
+type redisWebhookDeduper struct {
+	client *goredis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisWebhookDeduper creates a WebhookDeduper that remembers the webhook IDs in Redis for ttl,
+// zero uses 48 hours (how long Shopify retries a delivery).
+// Keys are prefixed with APP_NAME.
+func NewRedisWebhookDeduper(client *goredis.Client, ttl time.Duration) WebhookDeduper {
+	if ttl <= 0 {
+		ttl = defaultWebhookDedupTTL
+	}
+
+	return &redisWebhookDeduper{
+		client: client,
+		prefix: os.Getenv("APP_NAME") + ":shopify:webhook:",
+		ttl:    ttl,
+	}
+}
+
+// Seen marks the webhook as received and returns true if it already was
+func (d *redisWebhookDeduper) Seen(ctx context.Context, webhookID string) (bool, error) {
+	ok, err := d.client.SetNX(ctx, d.prefix+webhookID, 1, d.ttl).Result()
+	return !ok, err
+}
+
+// Forget removes the mark
+func (d *redisWebhookDeduper) Forget(ctx context.Context, webhookID string) error {
+	return d.client.Del(ctx, d.prefix+webhookID).Err()
+}
@@ -0,0 +1,113 @@
+package shopify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryDeduper is a test implementation of WebhookDeduper
+type memoryDeduper struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (m *memoryDeduper) Seen(_ context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := m.seen[id]
+	m.seen[id] = true
+	return seen, nil
+}
+
+func (m *memoryDeduper) Forget(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.seen, id)
+	return nil
+}
+
+func signWebhook(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func sendWebhook(receiver http.Handler, topic, id, body, signature string) int {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+	req.Header.Set("X-Shopify-Topic", topic)
+	req.Header.Set("X-Shopify-Shop-Domain", "abc.myshopify.com")
+	req.Header.Set("X-Shopify-Webhook-Id", id)
+	req.Header.Set("X-Shopify-Hmac-Sha256", signature)
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestWebhookReceiver(t *testing.T) {
+	secret := "app-secret"
+	receiver := NewWebhookReceiver(secret, WithWebhookDeduper(&memoryDeduper{seen: map[string]bool{}}))
+
+	var received []int
+	OnWebhook(receiver, "orders/create", func(ctx context.Context, webhook *Webhook, order *struct{ ID int }) error {
+		if webhook.Shop != "abc.myshopify.com" {
+			t.Errorf("Expected shop 'abc.myshopify.com', got '%s'", webhook.Shop)
+		}
+		received = append(received, order.ID)
+		return nil
+	})
+
+	body := `{"id":123}`
+	if code := sendWebhook(receiver, "orders/create", "1", body, "invalid"); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for invalid HMAC, got %d", code)
+	}
+
+	if code := sendWebhook(receiver, "orders/create", "1", body, signWebhook(secret, body)); code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", code)
+	}
+
+	// Duplicate delivery is dropped
+	if code := sendWebhook(receiver, "orders/create", "1", body, signWebhook(secret, body)); code != http.StatusOK {
+		t.Errorf("Expected 200 for duplicate, got %d", code)
+	}
+
+	if len(received) != 1 || received[0] != 123 {
+		t.Errorf("Expected one order 123, got %v", received)
+	}
+}
+
+func TestWebhookReceiver_HandlerError(t *testing.T) {
+	secret := "app-secret"
+	deduper := &memoryDeduper{seen: map[string]bool{}}
+	receiver := NewWebhookReceiver(secret, WithWebhookDeduper(deduper))
+
+	calls := 0
+	receiver.Handle(TopicAppUninstalled, func(ctx context.Context, webhook *Webhook) error {
+		calls++
+		if calls == 1 {
+			return errors.New("database down")
+		}
+		return nil
+	})
+
+	body := `{}`
+	if code := sendWebhook(receiver, TopicAppUninstalled, "2", body, signWebhook(secret, body)); code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 when the handler fails, got %d", code)
+	}
+
+	// The retry of a failed webhook is handled again
+	if code := sendWebhook(receiver, TopicAppUninstalled, "2", body, signWebhook(secret, body)); code != http.StatusOK {
+		t.Errorf("Expected 200 for the retry, got %d", code)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}